@@ -19,6 +19,7 @@ package flock
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"runtime"
@@ -26,6 +27,13 @@ import (
 	"time"
 )
 
+// ErrNotLocked is returned when an operation requires a lock that the *Flock does not hold.
+var ErrNotLocked = errors.New("lock not held")
+
+// conversionRetryDelay is the delay between attempts of LockContext and RLockContext
+// when the lock must be converted on a file descriptor that already holds a lock.
+const conversionRetryDelay = 10 * time.Millisecond
+
 type Option func(f *Flock)
 
 // SetFlag sets the flag used to create/open the file.
@@ -42,6 +50,39 @@ func SetPermissions(perm fs.FileMode) Option {
 	}
 }
 
+// UseOFDLocks makes Lock, RLock, TryLock, TryRLock, and Unlock
+// use open file description locks (F_OFD_SETLK and F_OFD_SETLKW) instead of flock(2),
+// like the byte-range functions always do on Linux.
+//
+// Open file description locks are POSIX record locks owned by the file descriptor rather than the process:
+// they interoperate with fcntl(2) locks taken by other processes,
+// and closing another descriptor for the same file does not release them.
+//
+// An exclusive open file description lock needs the file to be opened for writing:
+// the file is reopened in read-write mode when needed, which is not possible while a shared lock is held,
+// so use SetFlag(os.O_CREATE|os.O_RDWR) to Upgrade() shared locks.
+//
+// They are only available on Linux, and this option has no effect on other operating systems.
+func UseOFDLocks() Option {
+	return func(f *Flock) {
+		f.ofd = true
+	}
+}
+
+// Reentrant makes the *Flock count its holds:
+// each successful call to Lock, RLock, TryLock, TryRLock, LockContext, RLockContext,
+// TryLockContext, or TryRLockContext must be balanced by a call to Unlock,
+// and the lock is only released by the OS when the last hold is released.
+//
+// Taking a shared lock while the exclusive lock is held only counts a hold,
+// and when the last exclusive hold is released while shared holds remain, the lock is downgraded.
+// Unlock returns ErrNotLocked when there is no hold to release.
+func Reentrant() Option {
+	return func(f *Flock) {
+		f.reentrant = true
+	}
+}
+
 // Flock is the struct type to handle file locking. All fields are unexported,
 // with access to some of the fields provided by getter methods (Path() and Locked()).
 type Flock struct {
@@ -51,10 +92,51 @@ type Flock struct {
 	l    bool
 	r    bool
 
+	// ranges are the byte ranges locked by LockRange and friends, sorted by offset.
+	ranges []Range
+	// recordID is the identity of the file while the *Flock is in the table of byte-range locks.
+	recordID fileID
+	recorded bool
+
 	// flag is the flag used to create/open the file.
 	flag int
 	// perm is the OS permissions to set on the file.
 	perm fs.FileMode
+	// ofd selects open file description locks instead of flock(2).
+	ofd bool
+	// reentrant enables hold counting.
+	reentrant bool
+	// fair enables the in-process queue of waiters.
+	fair bool
+	// gen is incremented each time the whole-file lock is released, to detect stale lock handles.
+	gen uint64
+	// holds are the modes of the outstanding holds in reentrant mode (true for exclusive), oldest first.
+	holds []bool
+	// handles are the numbers of outstanding lock handles per mode without the Reentrant option.
+	handles [Exclusive + 1]int
+	// id is the identity of the file while the *Flock is in the registry of whole-file locks.
+	id         fileID
+	registered bool
+	// recordOwner enables the owner record, with the given description.
+	recordOwner bool
+	description string
+	// ownerWritten is true while the owner record of the *Flock is in the file.
+	ownerWritten bool
+	// heartbeat is the interval between touches of the lock file while a lock is held.
+	heartbeat time.Duration
+	// beat is closed to stop the heartbeat.
+	beat chan struct{}
+	// pending is the blocking lock call left waiting in the background by LockContext or RLockContext.
+	pending *pendingLock
+	// removeOnUnlock removes the lock file when the exclusive lock is released.
+	removeOnUnlock bool
+	// createParents creates the missing parent directories with parentPerm,
+	// owned by parentUID and parentGID if parentOwner is true.
+	createParents bool
+	parentPerm    fs.FileMode
+	parentOwner   bool
+	parentUID     int
+	parentGID     int
 }
 
 // New returns a new instance of *Flock. The only parameter
@@ -81,6 +163,11 @@ func New(path string, opts ...Option) *Flock {
 		opt(f)
 	}
 
+	if f.recordOwner {
+		// The owner record is written through the lock file descriptor.
+		f.flag = f.flag&^(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) | os.O_RDWR
+	}
+
 	return f
 }
 
@@ -95,7 +182,8 @@ func NewFlock(path string) *Flock {
 // Close is equivalent to calling Unlock.
 //
 // This will release the lock and close the underlying file descriptor.
-// It will not remove the file from disk, that's up to your application.
+// It will not remove the file from disk, that's up to your application,
+// unless the RemoveOnUnlock option is used.
 func (f *Flock) Close() error {
 	return f.Unlock()
 }
@@ -141,6 +229,20 @@ func (f *Flock) Stat() (fs.FileInfo, error) {
 	return os.Stat(f.path)
 }
 
+// File returns the open lock file while the *Flock holds a lock, or nil.
+// It can be used to read and write the content of the lock file under the lock:
+// it must not be closed, and must not be used once the lock is released.
+func (f *Flock) File() *os.File {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	if !f.held() {
+		return nil
+	}
+
+	return f.fh
+}
+
 func (f *Flock) String() string {
 	return f.path
 }
@@ -150,7 +252,7 @@ func (f *Flock) String() string {
 // - TryLock fails with error
 // - Context Done channel is closed.
 func (f *Flock) TryLockContext(ctx context.Context, retryDelay time.Duration) (bool, error) {
-	return tryCtx(ctx, f.TryLock, retryDelay)
+	return f.tryCtx(ctx, f.TryLock, retryDelay)
 }
 
 // TryRLockContext repeatedly tries to take a shared lock until one of the conditions is met:
@@ -158,34 +260,279 @@ func (f *Flock) TryLockContext(ctx context.Context, retryDelay time.Duration) (b
 // - TryRLock fails with error
 // - Context Done channel is closed.
 func (f *Flock) TryRLockContext(ctx context.Context, retryDelay time.Duration) (bool, error) {
-	return tryCtx(ctx, f.TryRLock, retryDelay)
+	return f.tryCtx(ctx, f.TryRLock, retryDelay)
 }
 
-func tryCtx(ctx context.Context, fn func() (bool, error), retryDelay time.Duration) (bool, error) {
+// LockContext is a blocking call to try and take an exclusive file lock,
+// which gives up when the context is done.
+// It waits in the kernel instead of polling like TryLockContext,
+// and returns the context error as soon as the context is done.
+//
+// The blocking call is made on a separate file descriptor that is handed over to the *Flock on success.
+// If the context is done first, that descriptor is left waiting in the background,
+// and the next call to LockContext or RLockContext waits on it instead of starting another one.
+// If no call takes it over, the lock is released and the descriptor closed as soon as the kernel grants it,
+// so the *Flock remains unlocked.
+// The options with side effects (RecordOwner, RemoveOnUnlock, and Heartbeat) only apply once the lock is handed over.
+//
+// If the *Flock already holds a lock, the new lock must be taken on the same file descriptor:
+// in that case, the function polls until the context is done,
+// converting a shared lock like TryUpgrade, which keeps the shared lock when the conversion fails.
+func (f *Flock) LockContext(ctx context.Context) error {
+	return f.lockCtx(ctx, true)
+}
+
+// RLockContext is a blocking call to try and take a shared file lock,
+// which gives up when the context is done.
+//
+// See LockContext() for more details.
+func (f *Flock) RLockContext(ctx context.Context) error {
+	return f.lockCtx(ctx, false)
+}
+
+// pendingLock is a blocking lock call made by LockContext or RLockContext on a clone of the *Flock,
+// which keeps waiting in the kernel after the context is done.
+type pendingLock struct {
+	shadow    *Flock
+	exclusive bool
+	// done is closed when the call returns, with its error in err.
+	done chan struct{}
+	err  error
+
+	mu sync.Mutex
+	// abandoned is true while no call waits for the lock: it is released as soon as it is granted.
+	abandoned bool
+	finished  bool
+}
+
+func (f *Flock) lockCtx(ctx context.Context, exclusive bool) error {
 	if ctx.Err() != nil {
-		return false, ctx.Err()
+		return ctx.Err()
+	}
+
+	f.m.Lock()
+
+	if f.fh != nil {
+		f.m.Unlock()
+
+		fn := f.TryRLock
+		if exclusive {
+			fn = f.tryUpgradeLock
+		}
+
+		_, err := f.tryCtx(ctx, fn, conversionRetryDelay)
+
+		return err
+	}
+
+	defer f.m.Unlock()
+
+	leave, err := f.waitTurn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer leave()
+
+	p, err := f.backgroundLock(ctx, exclusive)
+	if err != nil {
+		return err
 	}
 
-	for {
-		if ok, err := fn(); ok || err != nil {
-			return ok, err
+	select {
+	case <-p.done:
+		f.pending = nil
+
+		if p.err != nil {
+			return p.err
+		}
+
+		f.adopt(p.shadow)
+
+		return nil
+
+	case <-ctx.Done():
+		if !p.abandon() {
+			// The lock was granted in the meantime.
+			f.pending = nil
+
+			if p.err == nil {
+				_ = p.shadow.Unlock()
+			}
+		}
+
+		return ctx.Err()
+	}
+}
+
+// backgroundLock returns the blocking lock call to wait for,
+// reusing the call left in the background by a previous call if it is still waiting in the same mode.
+// A call in the other mode is waited for and released first.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) backgroundLock(ctx context.Context, exclusive bool) (*pendingLock, error) {
+	if p := f.pending; p != nil {
+		if p.exclusive == exclusive && p.claim() {
+			return p, nil
 		}
 
 		select {
+		case <-p.done:
 		case <-ctx.Done():
-			return false, ctx.Err()
-		case <-time.After(retryDelay):
+			return nil, ctx.Err()
+		}
+
+		f.pending = nil
+	}
+
+	// The shadow waits in the kernel on behalf of f, which already has its turn.
+	// It must not touch the file on its own, as it may be abandoned.
+	shadow := f.clone()
+	shadow.fair = false
+	shadow.recordOwner = false
+	shadow.removeOnUnlock = false
+	shadow.heartbeat = 0
+
+	p := &pendingLock{shadow: shadow, exclusive: exclusive, done: make(chan struct{})}
+
+	go func() {
+		var err error
+
+		if exclusive {
+			err = shadow.Lock()
+		} else {
+			err = shadow.RLock()
+		}
+
+		p.mu.Lock()
+		p.err = err
+		p.finished = true
+		abandoned := p.abandoned
+		p.mu.Unlock()
+
+		if abandoned && err == nil {
+			_ = shadow.Unlock()
 		}
+
+		close(p.done)
+	}()
+
+	f.pending = p
+
+	return p, nil
+}
+
+// claim marks the call as waited for again.
+// It returns false if the call already returned, in which case its lock was released if it was abandoned.
+func (p *pendingLock) claim() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished {
+		return false
+	}
+
+	p.abandoned = false
+
+	return true
+}
+
+// abandon marks the call as no longer waited for, so that its lock is released as soon as it is granted.
+// It returns false if the call already returned, in which case the caller must release its lock.
+func (p *pendingLock) abandon() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished {
+		return false
 	}
+
+	p.abandoned = true
+
+	return true
+}
+
+// tryUpgradeLock is TryLock for LockContext:
+// a shared lock held by the *Flock is converted like TryUpgrade does,
+// so that a failed non-atomic conversion takes the shared lock again instead of leaving the *Flock unlocked.
+func (f *Flock) tryUpgradeLock() (bool, error) {
+	f.m.Lock()
+
+	if f.l || !f.r {
+		f.m.Unlock()
+
+		return f.TryLock()
+	}
+
+	defer f.m.Unlock()
+
+	ok, _, err := f.convertLocked("LockContext", true, false)
+	if ok && f.reentrant {
+		// The conversion turned the most recent shared hold into an exclusive hold:
+		// keep the shared hold, and count a new exclusive hold instead.
+		f.holds[len(f.holds)-1] = false
+		f.holds = append(f.holds, true)
+	}
+
+	return ok, err
+}
+
+func (f *Flock) tryCtx(ctx context.Context, fn func() (bool, error), retryDelay time.Duration) (bool, error) {
+	return f.tryPolicy(ctx, fn, RetryPolicy{Backoff: ConstantBackoff(retryDelay)})
+}
+
+// clone returns an unlocked *Flock with the same path and options.
+func (f *Flock) clone() *Flock {
+	return &Flock{
+		path:           f.path,
+		flag:           f.flag,
+		perm:           f.perm,
+		ofd:            f.ofd,
+		reentrant:      f.reentrant,
+		fair:           f.fair,
+		recordOwner:    f.recordOwner,
+		description:    f.description,
+		heartbeat:      f.heartbeat,
+		removeOnUnlock: f.removeOnUnlock,
+		createParents:  f.createParents,
+		parentPerm:     f.parentPerm,
+		parentOwner:    f.parentOwner,
+		parentUID:      f.parentUID,
+		parentGID:      f.parentGID,
+	}
+}
+
+// adopt takes over the file handle and the locks held by g,
+// which must not be used afterward.
+func (f *Flock) adopt(g *Flock) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	f.fh, g.fh = g.fh, nil
+	f.l, g.l = g.l, false
+	f.r, g.r = g.r, false
+	f.ranges, g.ranges = g.ranges, nil
+	f.holds, g.holds = g.holds, nil
+	f.ownerWritten, g.ownerWritten = g.ownerWritten, false
+
+	f.adoptOwner(g)
+
+	g.register()
+	f.register()
+
+	g.stopHeartbeat()
+	f.writeOwner()
+	f.startHeartbeat()
 }
 
 func (f *Flock) setFh(flag int) error {
 	// open a new os.File instance
-	fh, err := os.OpenFile(f.path, flag, f.perm)
+	fh, err := f.openFile(flag)
 	if err != nil {
 		return err
 	}
 
+	trackFile(fh)
+
 	// set the file handle on the struct
 	f.fh = fh
 
@@ -200,14 +547,25 @@ func (f *Flock) resetFh() {
 		return
 	}
 
-	_ = f.fh.Close()
+	f.stopHeartbeat()
+
+	untrackFile(f.fh)
+
+	if !processRecordLocks || !parkFile(f.fh) {
+		_ = f.fh.Close()
+	}
 
 	f.fh = nil
 }
 
+// held reports whether any lock (whole-file or byte-range) is held.
+func (f *Flock) held() bool {
+	return f.l || f.r || len(f.ranges) > 0
+}
+
 // ensure the file handle is closed if no lock is held.
 func (f *Flock) ensureFhState() {
-	if f.l || f.r || f.fh == nil {
+	if f.held() || f.fh == nil {
 		return
 	}
 
@@ -217,6 +575,14 @@ func (f *Flock) ensureFhState() {
 func (f *Flock) reset() {
 	f.l = false
 	f.r = false
+	f.ranges = nil
+	f.holds = nil
+	f.handles = [Exclusive + 1]int{}
+	f.ownerWritten = false
+	f.gen++
+
+	f.syncRecords()
+	f.register()
 
 	f.resetFh()
 }
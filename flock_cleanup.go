@@ -0,0 +1,119 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// cleanupTimeout is how long the handler installed by HandleSignals waits for the busy *Flock values,
+// retrying every cleanupRetryDelay.
+const (
+	cleanupTimeout    = 100 * time.Millisecond
+	cleanupRetryDelay = time.Millisecond
+)
+
+var (
+	cleanupMu sync.Mutex
+	cleanups  = map[*Flock]struct{}{}
+)
+
+// HandleSignals installs a handler that, when the process receives one of the given signals
+// (SIGINT and SIGTERM if none is given), releases every lock registered with RegisterCleanup,
+// then raises the signal again with its default behavior, which usually terminates the process.
+// Signals cannot be raised again on Windows, where the process exits with status 1 instead.
+//
+// Releasing the locks runs the cleanups of the *Flock options,
+// such as clearing the owner record (RecordOwner) or removing the lock file (RemoveOnUnlock).
+// The kernel releases the locks when the process exits anyway,
+// but leaves the lock files and their content behind.
+// A *Flock that is still busy in another goroutine after a short while, such as one blocked in Lock, is skipped.
+//
+// HandleSignals takes over the shutdown of the process for the given signals:
+// the process terminates once the locks are released,
+// without waiting for the other handlers registered with signal.Notify,
+// and exits with status 1 if the signal raised again does not terminate it within a second.
+// An application that shuts down gracefully on these signals should release its locks itself instead.
+//
+// The returned function uninstalls the handler.
+func HandleSignals(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = defaultCleanupSignals
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(ch, signals...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		select {
+		case sig := <-ch:
+			runCleanups()
+			raise(sig)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// RegisterCleanup registers the *Flock for release by the handler installed by HandleSignals.
+func RegisterCleanup(f *Flock) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+
+	cleanups[f] = struct{}{}
+}
+
+// DeregisterCleanup removes the *Flock from the locks released by the handler installed by HandleSignals,
+// typically once it was unlocked normally.
+func DeregisterCleanup(f *Flock) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+
+	delete(cleanups, f)
+}
+
+// runCleanups releases every lock registered with RegisterCleanup, and clears the registrations.
+func runCleanups() {
+	cleanupMu.Lock()
+	flocks := cleanups
+	cleanups = map[*Flock]struct{}{}
+	cleanupMu.Unlock()
+
+	// A *Flock may be busy for a moment only, e.g. while its state is read.
+	deadline := time.Now().Add(cleanupTimeout)
+
+	for {
+		for f := range flocks {
+			if !f.m.TryLock() {
+				continue
+			}
+
+			_ = f.unlock()
+
+			f.m.Unlock()
+
+			delete(flocks, f)
+		}
+
+		if len(flocks) == 0 || time.Now().After(deadline) {
+			return
+		}
+
+		time.Sleep(cleanupRetryDelay)
+	}
+}
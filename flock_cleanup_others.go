@@ -0,0 +1,17 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build (!unix && !windows) || plan9
+
+package flock
+
+import "os"
+
+var defaultCleanupSignals = []os.Signal{os.Interrupt}
+
+// raise exits the process, as the signal cannot be raised again.
+func raise(os.Signal) {
+	os.Exit(1)
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var defaultCleanupSignals = []os.Signal{os.Interrupt, unix.SIGTERM}
+
+// raise sends the signal to the process again, with its default behavior.
+func raise(sig os.Signal) {
+	signal.Reset(sig)
+
+	if s, ok := sig.(syscall.Signal); ok {
+		_ = unix.Kill(os.Getpid(), s)
+	}
+
+	// The signal is delivered asynchronously: exit if it did not terminate the process in time.
+	time.Sleep(time.Second)
+	os.Exit(1)
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handleSignalsEnv is set to the path of the lock file when the test binary runs as the child of TestHandleSignals.
+const handleSignalsEnv = "FLOCK_TEST_HANDLE_SIGNALS"
+
+func TestHandleSignals(t *testing.T) {
+	if path := os.Getenv(handleSignalsEnv); path != "" {
+		handleSignalsChild(path)
+
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "signal.lock")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandleSignals$")
+	cmd.Env = append(os.Environ(), handleSignalsEnv+"="+path)
+
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+
+	err = cmd.Start()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	// wait for the child to hold the lock.
+	scanner := bufio.NewScanner(stdout)
+	require.True(t, scanner.Scan())
+	require.Equal(t, "ready", scanner.Text())
+	require.FileExists(t, path)
+
+	err = cmd.Process.Signal(syscall.SIGTERM)
+	require.NoError(t, err)
+
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+
+	require.ErrorAs(t, err, &exitErr)
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	require.True(t, ok)
+	assert.True(t, status.Signaled(), "the child exited with %v", exitErr)
+	assert.Equal(t, syscall.SIGTERM, status.Signal())
+
+	assert.NoFileExists(t, path)
+}
+
+func handleSignalsChild(path string) {
+	f := flock.New(path, flock.RemoveOnUnlock())
+
+	if err := f.Lock(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	flock.RegisterCleanup(f)
+	flock.HandleSignals()
+
+	fmt.Println("ready")
+
+	time.Sleep(time.Minute)
+
+	fmt.Fprintln(os.Stderr, "the signal did not terminate the process")
+	os.Exit(2)
+}
@@ -0,0 +1,21 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package flock
+
+import (
+	"os"
+	"syscall"
+)
+
+// SIGTERM is delivered for the close, logoff, and shutdown console events.
+var defaultCleanupSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// raise exits the process, as signals cannot be raised on Windows.
+func raise(os.Signal) {
+	os.Exit(1)
+}
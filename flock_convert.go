@@ -0,0 +1,114 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"io/fs"
+	"slices"
+)
+
+// Upgrade is a blocking call to convert the shared lock held by the *Flock into an exclusive lock.
+// It will wait until it is able to obtain the exclusive file lock.
+// It returns ErrNotLocked if the *Flock does not hold a shared lock.
+//
+// The returned boolean reports whether the conversion was atomic on the current backend.
+// A non-atomic conversion releases the shared lock before taking the exclusive lock,
+// so another process may take and release the lock in between:
+// anything read under the shared lock must be read again.
+// Conversions are atomic with POSIX record locks (UseOFDLocks, AIX, and Solaris),
+// and not atomic with flock(2) or on Windows.
+//
+// After a successful call, Locked() returns true and RLocked() returns false.
+// With the Reentrant option, the most recent hold becomes an exclusive hold.
+func (f *Flock) Upgrade() (bool, error) {
+	_, atomic, err := f.convert("Upgrade", true, true)
+	return atomic, err
+}
+
+// TryUpgrade tries to convert the shared lock held by the *Flock into an exclusive lock without blocking.
+// If another process holds a shared lock, the function returns false instead of waiting for the lock.
+//
+// The second boolean reports whether the conversion was atomic on the current backend, see Upgrade().
+// When a non-atomic conversion fails, the shared lock is taken again without blocking:
+// if that fails too, the *Flock is left unlocked and RLocked() returns false.
+func (f *Flock) TryUpgrade() (bool, bool, error) {
+	return f.convert("TryUpgrade", true, false)
+}
+
+// Downgrade converts the exclusive lock held by the *Flock into a shared lock.
+// It returns ErrNotLocked if the *Flock does not hold an exclusive lock.
+//
+// The returned boolean reports whether the conversion was atomic on the current backend.
+// A non-atomic conversion may let another process take and release the exclusive lock in between.
+// Downgrades are atomic with POSIX record locks (UseOFDLocks, AIX, and Solaris) and on Windows,
+// and not atomic with flock(2).
+//
+// After a successful call, Locked() returns false and RLocked() returns true.
+//
+// With the Reentrant option, the most recent exclusive hold becomes a shared hold,
+// and the lock stays exclusive while older exclusive holds remain.
+func (f *Flock) Downgrade() (bool, error) {
+	_, atomic, err := f.convert("Downgrade", false, true)
+	return atomic, err
+}
+
+func (f *Flock) convert(op string, exclusive, wait bool) (bool, bool, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.convertLocked(op, exclusive, wait)
+}
+
+// convertLocked is like convert, but the caller must hold the RW-mutex lock.
+func (f *Flock) convertLocked(op string, exclusive, wait bool) (bool, bool, error) {
+	switch {
+	case f.fh == nil:
+		return false, false, &fs.PathError{Op: op, Path: f.path, Err: ErrNotLocked}
+
+	case exclusive && f.l, !exclusive && f.r && !f.l:
+		// Already in the requested mode.
+		f.l, f.r = exclusive, !exclusive
+
+		return true, true, nil
+
+	case exclusive && !f.r, !exclusive && !f.l:
+		return false, false, &fs.PathError{Op: op, Path: f.path, Err: ErrNotLocked}
+	}
+
+	i := f.convertedHold(exclusive)
+
+	if i >= 0 && !exclusive && slices.Contains(f.holds[:i], true) {
+		// An older exclusive hold remains, so the lock held by the OS stays exclusive.
+		f.holds[i] = false
+
+		return true, true, nil
+	}
+
+	if !exclusive {
+		f.clearOwner()
+	}
+
+	ok, atomic, err := f.convertLock(exclusive, wait)
+
+	switch {
+	case ok:
+		f.l, f.r = exclusive, !exclusive
+
+		if i >= 0 {
+			f.holds[i] = exclusive
+		}
+
+	case !f.l && !f.r:
+		// The conversion failed and the lock was lost.
+		f.holds = nil
+	}
+
+	f.register()
+	f.writeOwner()
+	f.ensureFhState()
+
+	return ok, atomic, err
+}
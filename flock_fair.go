@@ -0,0 +1,137 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// fairKey identifies the queue of in-process waiters for a file:
+// its absolute path, with the symbolic links of the parent directory resolved.
+// The key does not depend on the existence or the identity of the file,
+// which may be created, removed, or replaced while waiters are queued.
+type fairKey string
+
+type fairWaiter struct {
+	f *Flock
+	// turn is closed when the waiter reaches the head of the queue.
+	turn chan struct{}
+}
+
+var (
+	fairMu     sync.Mutex
+	fairQueues = map[fairKey][]*fairWaiter{}
+)
+
+// Fair makes the *Flock wait in arrival order behind the other *Flock values of the process,
+// created with this option, that wait for the same file.
+// Files are matched by absolute path, with symbolic links resolved in the parent directory,
+// so relative paths and paths through linked directories share the queue,
+// even while the file does not exist yet or is replaced.
+//
+// Only the waiter at the head of the queue tries to take the lock:
+// Lock, RLock, LockContext, RLockContext, TryLockContext, TryRLockContext, and their policy variants
+// wait for their turn before trying, and leave the queue once they have the lock or give up.
+// TryLock and TryRLock return false while other waiters are queued.
+//
+// The queue is only shared within the process:
+// the OS decides the order between processes.
+func Fair() Option {
+	return func(f *Flock) {
+		f.fair = true
+	}
+}
+
+// waitTurn queues the *Flock behind the other fair waiters for the same file,
+// and waits until it reaches the head of the queue or the context is done.
+// The returned function must be called to leave the queue.
+func (f *Flock) waitTurn(ctx context.Context) (func(), error) {
+	if !f.fair {
+		return func() {}, nil
+	}
+
+	key := f.fairKey()
+	w := &fairWaiter{f: f, turn: make(chan struct{})}
+
+	fairMu.Lock()
+
+	fairQueues[key] = append(fairQueues[key], w)
+	if len(fairQueues[key]) == 1 {
+		close(w.turn)
+	}
+
+	fairMu.Unlock()
+
+	leave := func() { fairLeave(key, w) }
+
+	select {
+	case <-w.turn:
+		return leave, nil
+	case <-ctx.Done():
+		leave()
+
+		return nil, ctx.Err()
+	}
+}
+
+// queued reports whether other fair waiters are ahead of the *Flock for the same file.
+func (f *Flock) queued() bool {
+	if !f.fair {
+		return false
+	}
+
+	key := f.fairKey()
+
+	fairMu.Lock()
+	defer fairMu.Unlock()
+
+	q := fairQueues[key]
+
+	return len(q) > 0 && q[0].f != f
+}
+
+func (f *Flock) fairKey() fairKey {
+	path, err := filepath.Abs(f.path)
+	if err != nil {
+		path = f.path
+	}
+
+	// The parent directory may not exist yet with the CreateParents option.
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+
+	return fairKey(path)
+}
+
+// fairLeave removes w from the queue, and gives the turn to the next waiter if w was at the head.
+func fairLeave(key fairKey, w *fairWaiter) {
+	fairMu.Lock()
+	defer fairMu.Unlock()
+
+	q := fairQueues[key]
+
+	i := slices.Index(q, w)
+	if i < 0 {
+		return
+	}
+
+	q = slices.Delete(q, i, i+1)
+
+	if len(q) == 0 {
+		delete(fairQueues, key)
+		return
+	}
+
+	fairQueues[key] = q
+
+	if i == 0 {
+		close(q[0].turn)
+	}
+}
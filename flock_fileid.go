@@ -0,0 +1,14 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+// fileID identifies a file independently of the path used to open it:
+// device and inode numbers on UNIX-like operating systems,
+// volume serial number and file index on Windows.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
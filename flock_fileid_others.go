@@ -0,0 +1,22 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build (!unix && !windows) || plan9
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+func fileIDOf(fh *os.File) (fileID, error) {
+	return fileID{}, &fs.PathError{Op: "fileID", Path: fh.Name(), Err: errors.ErrUnsupported}
+}
+
+func pathFileID(path string) (fileID, error) {
+	return fileID{}, &fs.PathError{Op: "fileID", Path: path, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,41 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// fileIDOf returns the identity of the open file.
+func fileIDOf(fh *os.File) (fileID, error) {
+	fi, err := fh.Stat()
+	if err != nil {
+		return fileID{}, err
+	}
+
+	return statFileID(fi), nil
+}
+
+// pathFileID returns the identity of the file at path, following symbolic links.
+func pathFileID(path string) (fileID, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, err
+	}
+
+	return statFileID(fi), nil
+}
+
+func statFileID(fi fs.FileInfo) fileID {
+	// Note(ldez): don't replace `syscall.Stat_t` by `unix.Stat_t` because `FileInfo.Sys()` returns `syscall.Stat_t`
+	st := fi.Sys().(*syscall.Stat_t)
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+}
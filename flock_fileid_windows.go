@@ -0,0 +1,43 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package flock
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileIDOf returns the identity of the open file,
+// made of the volume serial number and the file index.
+func fileIDOf(fh *os.File) (fileID, error) {
+	var info windows.ByHandleFileInformation
+
+	err := windows.GetFileInformationByHandle(windows.Handle(fh.Fd()), &info)
+	if err != nil {
+		return fileID{}, &os.PathError{Op: "GetFileInformationByHandle", Path: fh.Name(), Err: err}
+	}
+
+	return fileID{
+		dev: uint64(info.VolumeSerialNumber),
+		ino: uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow),
+	}, nil
+}
+
+// pathFileID returns the identity of the file at path, following symbolic links.
+// Windows only exposes the file index through a handle, so the file is opened.
+func pathFileID(path string) (fileID, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return fileID{}, err
+	}
+
+	defer func() { _ = fh.Close() }()
+
+	return fileIDOf(fh)
+}
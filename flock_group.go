@@ -0,0 +1,172 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+)
+
+// GroupLock is a lock taken by LockAll and TryLockAll: a *Flock and the mode of its lock.
+type GroupLock struct {
+	// Flock is the *Flock to lock. It must not hold any lock.
+	Flock *Flock
+	// Mode is the mode of the lock: Shared or Exclusive.
+	Mode Mode
+}
+
+// groupMember is a *Flock of a group, with its canonical key.
+type groupMember struct {
+	f    *Flock
+	id   fileID
+	mode Mode
+}
+
+// LockAll is a blocking call to take several locks, which gives up when the context is done.
+//
+// The locks are taken in a canonical order, by device and inode (volume and file index on Windows), then path,
+// so that processes locking overlapping sets of files cannot deadlock each other, whatever the order of the arguments.
+// The missing lock files are created beforehand, when their flag includes os.O_CREATE, to be ordered by identity.
+//
+// It is all-or-nothing: if a lock cannot be taken, the locks already taken are released and the error is returned.
+// A *Flock cannot be given twice, nor can the same file be locked twice if one of the modes is Exclusive,
+// and a *Flock that already holds a lock, including a byte-range lock, is rejected.
+//
+// It returns the handles of the locks, in the order they were taken: release them with ReleaseAll.
+func LockAll(ctx context.Context, locks ...GroupLock) ([]*Held, error) {
+	return lockAll(locks, func(f *Flock, mode Mode) (*Held, error) {
+		return f.AcquireContext(ctx, mode)
+	})
+}
+
+// TryLockAll tries to take several locks without blocking.
+// If one of the locks is held by someone else,
+// the locks already taken are released, and it returns no handle and no error.
+//
+// See LockAll() for more details.
+func TryLockAll(locks ...GroupLock) ([]*Held, error) {
+	return lockAll(locks, func(f *Flock, mode Mode) (*Held, error) {
+		return f.TryAcquire(mode)
+	})
+}
+
+// ReleaseAll releases the locks of several handles, typically returned by LockAll or TryLockAll, in reverse order.
+// Releasing the handles leaves alone the locks taken outside of the group (see Held.Release).
+// It releases every lock even if some fail, and returns the first error.
+func ReleaseAll(held ...*Held) error {
+	var err error
+
+	for _, h := range slices.Backward(held) {
+		if releaseErr := h.Release(); err == nil {
+			err = releaseErr
+		}
+	}
+
+	return err
+}
+
+// lockAll takes the locks with acquire in the canonical order,
+// and releases the locks already taken if one of them fails.
+func lockAll(locks []GroupLock, acquire func(f *Flock, mode Mode) (*Held, error)) ([]*Held, error) {
+	members, err := groupMembers(locks)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make([]*Held, 0, len(members))
+
+	for _, m := range members {
+		h, err := acquire(m.f, m.mode)
+		if err == nil && h != nil {
+			held = append(held, h)
+			continue
+		}
+
+		_ = ReleaseAll(held...)
+
+		return nil, err
+	}
+
+	return held, nil
+}
+
+// groupMembers returns the locks sorted in the canonical order.
+func groupMembers(locks []GroupLock) ([]groupMember, error) {
+	members := make([]groupMember, 0, len(locks))
+	seen := make(map[*Flock]bool, len(locks))
+
+	for _, l := range locks {
+		f := l.Flock
+
+		if f == nil {
+			return nil, &fs.PathError{Op: "LockAll", Err: fs.ErrInvalid}
+		}
+
+		f.m.RLock()
+		held := f.held()
+		f.m.RUnlock()
+
+		if seen[f] || held || (l.Mode != Shared && l.Mode != Exclusive) {
+			return nil, &fs.PathError{Op: "LockAll", Path: f.path, Err: fs.ErrInvalid}
+		}
+
+		seen[f] = true
+
+		id, err := f.groupID()
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, groupMember{f: f, id: id, mode: l.Mode})
+	}
+
+	slices.SortFunc(members, func(a, b groupMember) int {
+		return cmp.Or(
+			cmp.Compare(a.id.dev, b.id.dev),
+			cmp.Compare(a.id.ino, b.id.ino),
+			cmp.Compare(a.f.path, b.f.path),
+		)
+	})
+
+	for i := 1; i < len(members); i++ {
+		a, b := members[i-1], members[i]
+
+		if a.id == b.id && a.id != (fileID{}) && (a.mode == Exclusive || b.mode == Exclusive) {
+			return nil, &fs.PathError{Op: "LockAll", Path: b.f.path, Err: fs.ErrInvalid}
+		}
+	}
+
+	return members, nil
+}
+
+// groupID returns the identity of the lock file, creating it if it is missing and its flag includes os.O_CREATE.
+// The zero identity is returned if the file cannot be identified, and the *Flock is ordered by path only.
+func (f *Flock) groupID() (fileID, error) {
+	f.m.RLock()
+	id, err := f.fileID()
+	f.m.RUnlock()
+
+	if err == nil {
+		return id, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) || f.flag&os.O_CREATE == 0 {
+		return fileID{}, nil
+	}
+
+	fh, err := f.openFile(f.flag)
+	if err != nil {
+		return fileID{}, err
+	}
+
+	defer func() { _ = fh.Close() }()
+
+	return fileIDOf(fh)
+}
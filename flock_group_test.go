@@ -0,0 +1,176 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryLockAll(t *testing.T) {
+	dir := t.TempDir()
+
+	config := flock.New(filepath.Join(dir, "config.lock"))
+	state := flock.New(filepath.Join(dir, "state.lock"))
+
+	locks := []flock.GroupLock{
+		{Flock: state, Mode: flock.Exclusive},
+		{Flock: config, Mode: flock.Shared},
+	}
+
+	held, err := flock.TryLockAll(locks...)
+	require.NoError(t, err)
+	require.Len(t, held, 2)
+
+	assert.True(t, config.RLocked())
+	assert.False(t, config.Locked())
+	assert.True(t, state.Locked())
+
+	err = flock.ReleaseAll(held...)
+	require.NoError(t, err)
+
+	assert.False(t, config.RLocked())
+	assert.False(t, state.Locked())
+
+	// nothing is kept locked when one of the locks is held by someone else.
+	holder := flock.New(state.Path())
+
+	locked, err := holder.TryLock()
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	held, err = flock.TryLockAll(locks[1], locks[0])
+	require.NoError(t, err)
+	assert.Empty(t, held)
+
+	assert.False(t, config.RLocked())
+	assert.False(t, state.Locked())
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+}
+
+func TestTryLockAll_invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	f := flock.New(filepath.Join(dir, "a.lock"))
+	exclusive := flock.GroupLock{Flock: f, Mode: flock.Exclusive}
+
+	_, err := flock.TryLockAll(exclusive, exclusive)
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	shared := flock.GroupLock{Flock: flock.New(f.Path()), Mode: flock.Shared}
+
+	_, err = flock.TryLockAll(exclusive, shared)
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	_, err = flock.TryLockAll(flock.GroupLock{Flock: f})
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	assert.False(t, f.Locked())
+}
+
+func TestTryLockAll_locked(t *testing.T) {
+	dir := t.TempDir()
+
+	a := flock.New(filepath.Join(dir, "a.lock"))
+	b := flock.New(filepath.Join(dir, "b.lock"))
+
+	err := a.Lock()
+	require.NoError(t, err)
+
+	holder := flock.New(b.Path())
+
+	locked, err := holder.TryLock()
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	// a lock taken outside of the group is not released when the group fails.
+	locks := []flock.GroupLock{
+		{Flock: a, Mode: flock.Exclusive},
+		{Flock: b, Mode: flock.Exclusive},
+	}
+
+	_, err = flock.TryLockAll(locks...)
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	assert.True(t, a.Locked())
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	err = a.Unlock()
+	require.NoError(t, err)
+
+	// nor is a byte-range lock.
+	c := flock.New(filepath.Join(dir, "c.lock"), flock.SetFlag(os.O_CREATE|os.O_RDWR))
+
+	err = c.LockRange(0, 10)
+	require.NoError(t, err)
+
+	_, err = flock.TryLockAll(flock.GroupLock{Flock: c, Mode: flock.Exclusive})
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	assert.Equal(t, []flock.Range{{Offset: 0, Length: 10, Exclusive: true}}, c.Ranges())
+
+	err = c.Unlock()
+	require.NoError(t, err)
+}
+
+func TestLockAll_order(t *testing.T) {
+	dir := t.TempDir()
+
+	paths := []string{
+		filepath.Join(dir, "a.lock"),
+		filepath.Join(dir, "b.lock"),
+		filepath.Join(dir, "c.lock"),
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			locks := make([]flock.GroupLock, len(paths))
+
+			// every goroutine gives the locks in a different order.
+			for j, path := range paths {
+				locks[(i+j)%len(paths)] = flock.GroupLock{Flock: flock.New(path), Mode: flock.Exclusive}
+			}
+
+			if i%2 == 1 {
+				locks[0], locks[1] = locks[1], locks[0]
+			}
+
+			for range 200 {
+				held, err := flock.LockAll(ctx, locks...)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				assert.NoError(t, flock.ReleaseAll(held...))
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,74 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import "time"
+
+// Heartbeat makes the *Flock touch the lock file every interval while it holds a lock,
+// so that the modification time reported by Stat() tells a live holder from an abandoned lock file
+// (see IsStale()).
+// The heartbeat starts with the first lock, whole-file or byte-range,
+// and stops when the last lock is released.
+func Heartbeat(interval time.Duration) Option {
+	return func(f *Flock) {
+		f.heartbeat = interval
+	}
+}
+
+// startHeartbeat starts touching the lock file, unless it is already done.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) startHeartbeat() {
+	if f.heartbeat <= 0 || f.beat != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	f.beat = stop
+
+	go func() {
+		ticker := time.NewTicker(f.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				f.touch()
+			}
+		}
+	}()
+}
+
+// stopHeartbeat stops touching the lock file.
+// It does not wait for the heartbeat goroutine, which may be waiting for the RW-mutex lock.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) stopHeartbeat() {
+	if f.beat == nil {
+		return
+	}
+
+	close(f.beat)
+	f.beat = nil
+}
+
+// touch refreshes the modification time of the locked file through its descriptor.
+// It does nothing if the lock file was removed or replaced,
+// so that the heartbeat does not make a file it does not lock look alive.
+func (f *Flock) touch() {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	if f.fh == nil {
+		return
+	}
+
+	if current, err := f.current(); err != nil || !current {
+		return
+	}
+
+	_ = touchFile(f.fh, time.Now())
+}
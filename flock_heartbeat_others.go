@@ -0,0 +1,20 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || (!unix && !windows) || plan9
+
+package flock
+
+import (
+	"os"
+	"time"
+)
+
+// touchFile sets the access and modification times of the file open as fh.
+// There is no futimes on AIX: the path is used instead,
+// which the caller checked to name the locked file.
+func touchFile(fh *os.File, t time.Time) error {
+	return os.Chtimes(fh.Name(), t, t)
+}
@@ -0,0 +1,28 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"io/fs"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// touchFile sets the access and modification times of the file open as fh.
+func touchFile(fh *os.File, t time.Time) error {
+	tv := unix.NsecToTimeval(t.UnixNano())
+
+	err := unix.Futimes(int(fh.Fd()), []unix.Timeval{tv, tv})
+	if err != nil {
+		return &fs.PathError{Op: "futimes", Path: fh.Name(), Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// touchFile sets the access and modification times of the file open as fh.
+// A handle opened read-only cannot change the times of the file:
+// the path is used instead, which names the same file, as an open file cannot be removed or replaced on Windows.
+func touchFile(fh *os.File, t time.Time) error {
+	ft := windows.NsecToFiletime(t.UnixNano())
+
+	err := windows.SetFileTime(windows.Handle(fh.Fd()), nil, &ft, &ft)
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		return os.Chtimes(fh.Name(), t, t)
+	}
+
+	if err != nil {
+		return &fs.PathError{Op: "SetFileTime", Path: fh.Name(), Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strconv"
+	"time"
+)
+
+// ErrReleased is returned when releasing a lock handle that was already released,
+// or whose lock was released through the *Flock.
+var ErrReleased = errors.New("lock already released")
+
+// Mode is the mode of a file lock.
+type Mode int
+
+const (
+	// Unlocked means that no lock is held.
+	Unlocked Mode = iota
+	// Shared is a shared (read) lock.
+	Shared
+	// Exclusive is an exclusive (write) lock.
+	Exclusive
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Unlocked:
+		return "unlocked"
+	case Shared:
+		return "shared"
+	case Exclusive:
+		return "exclusive"
+	default:
+		return "Mode(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
+// Held is a handle to a lock acquired through Acquire, AcquireContext, or TryAcquire.
+// Only the handle can release the lock it acquired:
+// releasing a shared lock never drops an exclusive lock acquired through another handle, and vice versa.
+//
+// With the Reentrant option, each handle is a hold, and the lock is released by the OS with the last hold.
+// Without it, handles in the same mode share the lock, which is released by the last of them.
+type Held struct {
+	f    *Flock
+	mode Mode
+	at   time.Time
+
+	// gen is the generation of the *Flock when the lock was acquired.
+	gen      uint64
+	released bool
+}
+
+// Acquire is a blocking call to take a lock in the given mode,
+// which returns a handle to release it.
+func (f *Flock) Acquire(mode Mode) (*Held, error) {
+	if h := f.shareExclusive(mode); h != nil {
+		return h, nil
+	}
+
+	var err error
+
+	switch mode {
+	case Exclusive:
+		err = f.Lock()
+	case Shared:
+		err = f.RLock()
+	default:
+		err = &fs.PathError{Op: "Acquire", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.newHeld(mode), nil
+}
+
+// AcquireContext is a blocking call to take a lock in the given mode, which gives up when the context is done.
+// It returns a handle to release the lock.
+//
+// See LockContext() for more details.
+func (f *Flock) AcquireContext(ctx context.Context, mode Mode) (*Held, error) {
+	if h := f.shareExclusive(mode); h != nil {
+		return h, nil
+	}
+
+	var err error
+
+	switch mode {
+	case Exclusive:
+		err = f.LockContext(ctx)
+	case Shared:
+		err = f.RLockContext(ctx)
+	default:
+		err = &fs.PathError{Op: "AcquireContext", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.newHeld(mode), nil
+}
+
+// TryAcquire tries to take a lock in the given mode without blocking,
+// and returns a handle to release it.
+// If the lock is held by someone else, it returns a nil handle and no error.
+func (f *Flock) TryAcquire(mode Mode) (*Held, error) {
+	if h := f.shareExclusive(mode); h != nil {
+		return h, nil
+	}
+
+	var (
+		locked bool
+		err    error
+	)
+
+	switch mode {
+	case Exclusive:
+		locked, err = f.TryLock()
+	case Shared:
+		locked, err = f.TryRLock()
+	default:
+		err = &fs.PathError{Op: "TryAcquire", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if err != nil || !locked {
+		return nil, err
+	}
+
+	return f.newHeld(mode), nil
+}
+
+func (f *Flock) newHeld(mode Mode) *Held {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.newHeldLocked(mode)
+}
+
+// newHeldLocked is like newHeld, but the caller must hold the RW-mutex lock.
+func (f *Flock) newHeldLocked(mode Mode) *Held {
+	if !f.reentrant {
+		f.handles[mode]++
+	}
+
+	return &Held{f: f, mode: mode, at: time.Now(), gen: f.gen}
+}
+
+// shareExclusive returns a shared handle on the exclusive lock of a *Flock without the Reentrant option,
+// as RLock would downgrade it.
+// It returns nil if the shared lock must be taken.
+func (f *Flock) shareExclusive(mode Mode) *Held {
+	if mode != Shared {
+		return nil
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.reentrant || !f.l {
+		return nil
+	}
+
+	// Releasing the exclusive lock downgrades it for the shared handles.
+	f.r = true
+
+	return f.newHeldLocked(mode)
+}
+
+// Flock returns the *Flock the lock was acquired through.
+func (h *Held) Flock() *Flock {
+	return h.f
+}
+
+// Mode returns the mode the lock was acquired in.
+func (h *Held) Mode() Mode {
+	return h.mode
+}
+
+// AcquiredAt returns the time when the lock was acquired.
+func (h *Held) AcquiredAt() time.Time {
+	return h.at
+}
+
+// Release releases the lock acquired by the handle.
+// If the *Flock still holds a lock for other handles, only the part acquired by this handle is released:
+// the lock is kept while other handles hold it in the same mode,
+// an exclusive lock is downgraded when a shared lock is still held,
+// and a shared lock is left alone when an exclusive lock is held.
+//
+// Release returns ErrReleased if the handle was already released,
+// or if the lock was released in the meantime by Unlock or by another handle.
+func (h *Held) Release() error {
+	f := h.f
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if h.released || h.gen != f.gen {
+		return &fs.PathError{Op: "Release", Path: f.path, Err: ErrReleased}
+	}
+
+	h.released = true
+
+	ok, err := f.release(h.mode == Exclusive)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return &fs.PathError{Op: "Release", Path: f.path, Err: ErrReleased}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import "io/fs"
+
+// LockKind is the kind of a lock reported by the kernel.
+type LockKind string
+
+const (
+	// KindFlock is a flock(2) lock.
+	KindFlock LockKind = "FLOCK"
+	// KindPOSIX is a POSIX record lock, owned by a process.
+	KindPOSIX LockKind = "POSIX"
+	// KindOFD is an open file description lock.
+	KindOFD LockKind = "OFDLCK"
+)
+
+// Holder describes a lock on a file, held or waited for by a process, as reported by the kernel.
+type Holder struct {
+	// PID is the process that took the lock, or -1 for open file description locks,
+	// which do not belong to a process.
+	// For flock(2) and open file description locks shared across processes,
+	// only the process that took the lock is reported.
+	PID int
+	// Kind is the kind of lock.
+	Kind LockKind
+	// Mode is Shared or Exclusive.
+	Mode Mode
+	// Range is the locked byte range, whose Exclusive field matches Mode.
+	// flock(2) locks always cover the whole file.
+	Range Range
+	// Blocked is true for a waiter that is blocked by the holders of the lock.
+	Blocked bool
+}
+
+// Holders returns the locks held and waited for on the file by every process,
+// including the current one.
+//
+// It is only supported on Linux, where it reads /proc/locks.
+// On other operating systems, it returns an error wrapping errors.ErrUnsupported.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func (f *Flock) Holders() ([]Holder, error) {
+	f.m.RLock()
+	id, err := f.fileID()
+	f.m.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	holders, err := lockHolders(id)
+	if err != nil {
+		return nil, &fs.PathError{Op: "Holders", Path: f.path, Err: err}
+	}
+
+	return holders, nil
+}
+
+// LockHolders returns the locks held and waited for on the file at path by every process.
+// It does not create the file.
+//
+// See Flock.Holders() for more details.
+func LockHolders(path string) ([]Holder, error) {
+	id, err := pathFileID(path)
+	if err != nil {
+		return nil, err
+	}
+
+	holders, err := lockHolders(id)
+	if err != nil {
+		return nil, &fs.PathError{Op: "LockHolders", Path: path, Err: err}
+	}
+
+	return holders, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package flock
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockHolders returns the locks on the file reported by /proc/locks.
+func lockHolders(id fileID) ([]Holder, error) {
+	fh, err := os.Open("/proc/locks")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = fh.Close() }()
+
+	return parseProcLocks(fh, id)
+}
+
+// parseProcLocks parses the content of /proc/locks, keeping the locks on the file.
+// Each line looks like:
+//
+//	1: FLOCK  ADVISORY  READ 10710 fe:00:9619138 0 EOF
+//	1: -> FLOCK  ADVISORY  WRITE 10713 fe:00:9619138 0 EOF
+//
+// where the waiters blocked by a lock follow it with an arrow,
+// the file is identified by the major and minor device numbers (in hexadecimal) and the inode number,
+// and the range is given by its first and last bytes.
+// Lines that cannot be parsed, such as leases, are skipped.
+func parseProcLocks(r io.Reader, id fileID) ([]Holder, error) {
+	var holders []Holder
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		h, lockID, ok := parseProcLock(scanner.Text())
+		if ok && lockID == id {
+			holders = append(holders, h)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return holders, nil
+}
+
+// parseProcLock parses a line of /proc/locks.
+func parseProcLock(line string) (Holder, fileID, bool) {
+	fields := strings.Fields(line)
+
+	if len(fields) > 0 && strings.HasSuffix(fields[0], ":") {
+		fields = fields[1:]
+	}
+
+	var h Holder
+
+	if len(fields) > 0 && fields[0] == "->" {
+		h.Blocked = true
+		fields = fields[1:]
+	}
+
+	// kind, ADVISORY or MANDATORY, mode, pid, file, start, end
+	if len(fields) != 7 {
+		return Holder{}, fileID{}, false
+	}
+
+	h.Kind = LockKind(fields[0])
+
+	switch fields[2] {
+	case "READ":
+		h.Mode = Shared
+	case "WRITE":
+		h.Mode = Exclusive
+	default:
+		return Holder{}, fileID{}, false
+	}
+
+	pid, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return Holder{}, fileID{}, false
+	}
+
+	h.PID = pid
+
+	id, ok := parseProcFile(fields[4])
+	if !ok {
+		return Holder{}, fileID{}, false
+	}
+
+	start, err := strconv.ParseInt(fields[5], 10, 64)
+	if err != nil {
+		return Holder{}, fileID{}, false
+	}
+
+	h.Range = Range{Offset: start, Exclusive: h.Mode == Exclusive}
+
+	if fields[6] != "EOF" {
+		end, err := strconv.ParseInt(fields[6], 10, 64)
+		if err != nil || end < start {
+			return Holder{}, fileID{}, false
+		}
+
+		h.Range.Length = end - start + 1
+	}
+
+	return h, id, true
+}
+
+// parseProcFile parses the major:minor:inode identity of a file in /proc/locks.
+func parseProcFile(s string) (fileID, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return fileID{}, false
+	}
+
+	major, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return fileID{}, false
+	}
+
+	minor, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return fileID{}, false
+	}
+
+	ino, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return fileID{}, false
+	}
+
+	return fileID{dev: unix.Mkdev(uint32(major), uint32(minor)), ino: ino}, true
+}
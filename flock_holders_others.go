@@ -0,0 +1,14 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package flock
+
+import "errors"
+
+func lockHolders(fileID) ([]Holder, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyRunning is returned by SingleInstance when another instance of the application is running.
+var ErrAlreadyRunning = errors.New("already running")
+
+// InstanceError is returned by SingleInstance when another instance of the application is running.
+// It wraps ErrAlreadyRunning.
+type InstanceError struct {
+	// App is the name of the application.
+	App string
+	// Path is the path of the lock file.
+	Path string
+	// Owner is the owner record of the running instance, or nil if it could not be read.
+	Owner *Owner
+}
+
+func (e *InstanceError) Error() string {
+	msg := e.App + " is " + ErrAlreadyRunning.Error()
+
+	if e.Owner != nil {
+		msg += " (" + e.Owner.String() + ", started " + e.Owner.Started.Format(time.RFC3339) + ")"
+	}
+
+	return msg
+}
+
+func (e *InstanceError) Unwrap() error {
+	return ErrAlreadyRunning
+}
+
+// SingleInstance takes the exclusive lock of the application without blocking,
+// so that only one instance of the application runs at a time.
+// The lock must be held for the life of the process, and released with Unlock.
+//
+// The lock file is named after the application,
+// in the first directory among $XDG_RUNTIME_DIR (per user), /run/lock (system-wide),
+// and the directory returned by os.TempDir, where the lock file exists or can be created.
+// In the directories shared by the users, the name of the lock file includes the user ID on UNIX-like operating systems,
+// and an existing lock file is only used if it is a regular file of the current user:
+// another user cannot create it in advance, or replace it by a symbolic link to another file.
+// The options are applied to the *Flock, which records its owner (see RecordOwner)
+// with the application name as description.
+//
+// If another instance holds the lock, it returns an *InstanceError describing the owner of the lock.
+func SingleInstance(appName string, opts ...Option) (*Flock, error) {
+	if appName == "" || appName == "." || appName == ".." || strings.ContainsAny(appName, `/\`) {
+		return nil, &fs.PathError{Op: "SingleInstance", Path: appName, Err: fs.ErrInvalid}
+	}
+
+	f := New("", append([]Option{RecordOwner(appName)}, opts...)...)
+	f.flag |= noFollow
+
+	// The permissions of the options apply when instancePath creates the lock file.
+	path, err := instancePath(appName, f.perm)
+	if err != nil {
+		return nil, err
+	}
+
+	f.path = path
+
+	locked, err := f.TryLock()
+	if err != nil {
+		return nil, err
+	}
+
+	if !locked {
+		owner, _ := ReadOwner(f.path)
+
+		return nil, &InstanceError{App: appName, Path: f.path, Owner: owner}
+	}
+
+	return f, nil
+}
+
+// instancePath returns the path of the lock file of SingleInstance for the application.
+// A directory is used if the lock file can be used (see instanceFile):
+// a directory that exists but is not writable (e.g. /run/lock for a regular user) is skipped.
+func instancePath(appName string, perm fs.FileMode) (string, error) {
+	name := appName + ".lock"
+
+	// The directories shared by the users get a lock file per user.
+	sharedName := name
+	if uid := os.Getuid(); uid >= 0 {
+		sharedName = appName + "." + strconv.Itoa(uid) + ".lock"
+	}
+
+	var paths []string
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+
+	paths = append(paths, filepath.Join("/run/lock", sharedName))
+
+	for _, path := range paths {
+		if instanceFile(path, perm) {
+			return path, nil
+		}
+	}
+
+	path := filepath.Join(os.TempDir(), sharedName)
+	if !instanceFile(path, perm) {
+		return "", &fs.PathError{Op: "SingleInstance", Path: path, Err: fs.ErrPermission}
+	}
+
+	return path, nil
+}
+
+// instanceFile reports whether the lock file at path can be used by SingleInstance:
+// it is created with the given permissions, or it is a regular file of the current user.
+func instanceFile(path string, perm fs.FileMode) bool {
+	// The file is created exclusively, so that no lock of this process is held on it when closing it.
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+	if err == nil {
+		_ = fh.Close()
+
+		return true
+	}
+
+	if !errors.Is(err, fs.ErrExist) {
+		return false
+	}
+
+	fi, err := os.Lstat(path)
+
+	return err == nil && fi.Mode().IsRegular() && ownedFile(fi)
+}
@@ -0,0 +1,17 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris
+
+package flock
+
+import "io/fs"
+
+const noFollow = 0
+
+// ownedFile assumes that the file belongs to the current user, as it cannot be checked.
+func ownedFile(fs.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleInstance(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	f, err := flock.SingleInstance("app")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "app.lock"), f.Path())
+
+	_, err = flock.SingleInstance("app")
+	require.ErrorIs(t, err, flock.ErrAlreadyRunning)
+
+	var instance *flock.InstanceError
+
+	require.ErrorAs(t, err, &instance)
+	assert.Equal(t, "app", instance.App)
+	require.NotNil(t, instance.Owner)
+	assert.Equal(t, os.Getpid(), instance.Owner.PID)
+	assert.Equal(t, "app", instance.Owner.Description)
+	assert.Contains(t, err.Error(), "app is already running")
+
+	err = f.Unlock()
+	require.NoError(t, err)
+
+	f, err = flock.SingleInstance("app")
+	require.NoError(t, err)
+
+	err = f.Unlock()
+	require.NoError(t, err)
+
+	_, err = flock.SingleInstance("../app")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestSingleInstance_notWritable(t *testing.T) {
+	// a file in place of the runtime directory cannot hold the lock file.
+	runtimeDir := filepath.Join(t.TempDir(), "runtime")
+
+	err := os.WriteFile(runtimeDir, nil, 0o600)
+	require.NoError(t, err)
+
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	f, err := flock.SingleInstance("flock-test-app", flock.RemoveOnUnlock())
+	require.NoError(t, err)
+	assert.NotEqual(t, runtimeDir, filepath.Dir(f.Path()))
+
+	err = f.Unlock()
+	require.NoError(t, err)
+}
+
+func TestSingleInstance_symlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires a privilege on Windows")
+	}
+
+	dir := t.TempDir()
+
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	// a lock file replaced by a symbolic link to another file is not used.
+	target := filepath.Join(dir, "target")
+
+	err := os.WriteFile(target, []byte("content"), 0o600)
+	require.NoError(t, err)
+
+	err = os.Symlink(target, filepath.Join(dir, "flock-test-app.lock"))
+	require.NoError(t, err)
+
+	f, err := flock.SingleInstance("flock-test-app", flock.RemoveOnUnlock())
+	require.NoError(t, err)
+	assert.NotEqual(t, dir, filepath.Dir(f.Path()))
+	assert.Contains(t, filepath.Base(f.Path()), strconv.Itoa(os.Getuid()))
+
+	err = f.Unlock()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+}
@@ -0,0 +1,26 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// noFollow makes the lock file of SingleInstance fail to open if it was replaced by a symbolic link.
+const noFollow = unix.O_NOFOLLOW
+
+// ownedFile reports whether the file belongs to the current user.
+func ownedFile(fi fs.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+
+	return ok && int(st.Uid) == os.Getuid()
+}
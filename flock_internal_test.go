@@ -8,9 +8,13 @@
 package flock
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -68,3 +72,553 @@ func TestFlock_fh_onError_dir(t *testing.T) {
 	err = lock.Unlock()
 	require.NoError(t, err)
 }
+
+func Test_setRange(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		ranges   []Range
+		r        Range
+		merge    bool
+		expected []Range
+	}{
+		{
+			desc:     "empty",
+			r:        Range{Offset: 10, Length: 5, Exclusive: true},
+			merge:    true,
+			expected: []Range{{Offset: 10, Length: 5, Exclusive: true}},
+		},
+		{
+			desc:     "merge adjacent",
+			ranges:   []Range{{Offset: 0, Length: 10}, {Offset: 20, Length: 10}},
+			r:        Range{Offset: 10, Length: 10},
+			merge:    true,
+			expected: []Range{{Offset: 0, Length: 30}},
+		},
+		{
+			desc:   "no merge",
+			ranges: []Range{{Offset: 0, Length: 10}},
+			r:      Range{Offset: 10, Length: 10},
+			expected: []Range{
+				{Offset: 0, Length: 10},
+				{Offset: 10, Length: 10},
+			},
+		},
+		{
+			desc:   "convert middle",
+			ranges: []Range{{Offset: 0, Length: 30}},
+			r:      Range{Offset: 10, Length: 10, Exclusive: true},
+			merge:  true,
+			expected: []Range{
+				{Offset: 0, Length: 10},
+				{Offset: 10, Length: 10, Exclusive: true},
+				{Offset: 20, Length: 10},
+			},
+		},
+		{
+			desc:     "extend to end of file",
+			ranges:   []Range{{Offset: 0, Length: 10}, {Offset: 50, Length: 10}},
+			r:        Range{Offset: 5},
+			merge:    true,
+			expected: []Range{{Offset: 0}},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, setRange(test.ranges, test.r, test.merge))
+		})
+	}
+}
+
+func Test_clearRange(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		ranges   []Range
+		r        Range
+		expected []Range
+	}{
+		{
+			desc:   "split",
+			ranges: []Range{{Offset: 0, Length: 30, Exclusive: true}},
+			r:      Range{Offset: 10, Length: 10},
+			expected: []Range{
+				{Offset: 0, Length: 10, Exclusive: true},
+				{Offset: 20, Length: 10, Exclusive: true},
+			},
+		},
+		{
+			desc:     "split end of file",
+			ranges:   []Range{{Offset: 10}},
+			r:        Range{Offset: 0, Length: 20},
+			expected: []Range{{Offset: 20}},
+		},
+		{
+			desc:     "all",
+			ranges:   []Range{{Offset: 0, Length: 10}, {Offset: 20}},
+			r:        Range{},
+			expected: nil,
+		},
+		{
+			desc:     "no overlap",
+			ranges:   []Range{{Offset: 0, Length: 10}},
+			r:        Range{Offset: 10, Length: 10},
+			expected: []Range{{Offset: 0, Length: 10}},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, clearRange(test.ranges, test.r))
+		})
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	var delays []time.Duration
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		delay, ok := backoff(attempt, 0)
+		require.True(t, ok)
+
+		delays = append(delays, delay)
+	}
+
+	expected := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond,
+		50 * time.Millisecond,
+	}
+
+	assert.Equal(t, expected, delays)
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	backoff := DecorrelatedJitterBackoff(10*time.Millisecond, time.Second).WithMaxDelay(100 * time.Millisecond)
+
+	var prev time.Duration
+
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay, ok := backoff(attempt, prev)
+		require.True(t, ok)
+
+		assert.GreaterOrEqual(t, delay, 10*time.Millisecond)
+		assert.LessOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, max(prev, 10*time.Millisecond)*3)
+
+		prev = delay
+	}
+}
+
+func Test_retry(t *testing.T) {
+	errTransient := errors.New("transient")
+
+	var attempts int
+
+	fn := func() (bool, error) {
+		attempts++
+
+		switch attempts {
+		case 1:
+			return false, errTransient
+		case 2:
+			return false, nil
+		default:
+			return true, nil
+		}
+	}
+
+	policy := RetryPolicy{
+		Backoff: ConstantBackoff(time.Millisecond),
+		RetryOn: []error{errTransient},
+	}
+
+	locked, err := retry(t.Context(), fn, policy)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	assert.Equal(t, 3, attempts)
+
+	// the error is returned when it is not retried.
+	attempts = 0
+
+	locked, err = retry(t.Context(), fn, RetryPolicy{})
+	require.ErrorIs(t, err, errTransient)
+	assert.False(t, locked)
+
+	// the last error is returned when the policy stops retrying.
+	attempts = 0
+	policy.Backoff = policy.Backoff.WithMaxAttempts(1)
+
+	locked, err = retry(t.Context(), fn, policy)
+	require.ErrorIs(t, err, errTransient)
+	assert.False(t, locked)
+	assert.Equal(t, 1, attempts)
+}
+
+func Test_runCleanups(t *testing.T) {
+	dir := t.TempDir()
+
+	registered := New(filepath.Join(dir, "registered.lock"), RemoveOnUnlock())
+
+	err := registered.Lock()
+	require.NoError(t, err)
+
+	deregistered := New(filepath.Join(dir, "deregistered.lock"))
+
+	err = deregistered.Lock()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = deregistered.Unlock() })
+
+	RegisterCleanup(registered)
+	RegisterCleanup(deregistered)
+	DeregisterCleanup(deregistered)
+
+	runCleanups()
+
+	assert.False(t, registered.Locked())
+	assert.True(t, deregistered.Locked())
+
+	if runtime.GOOS != "windows" {
+		assert.NoFileExists(t, registered.Path())
+	}
+
+	// the registrations are cleared.
+	assert.Empty(t, cleanups)
+}
+
+func Test_runCleanups_busy(t *testing.T) {
+	dir := t.TempDir()
+
+	// a *Flock read for a moment is released.
+	reading := New(filepath.Join(dir, "reading.lock"))
+
+	err := reading.Lock()
+	require.NoError(t, err)
+
+	// a *Flock busy for longer is skipped.
+	busy := New(filepath.Join(dir, "busy.lock"))
+
+	err = busy.Lock()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = busy.Unlock() })
+
+	RegisterCleanup(reading)
+	RegisterCleanup(busy)
+
+	reading.m.RLock()
+	busy.m.Lock()
+
+	time.AfterFunc(10*time.Millisecond, reading.m.RUnlock)
+
+	start := time.Now()
+
+	runCleanups()
+
+	busy.m.Unlock()
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, reading.Locked())
+	assert.True(t, busy.Locked())
+}
+
+func TestHeld_Release_ranges(t *testing.T) {
+	if runtime.GOOS == "aix" || runtime.GOOS == "solaris" {
+		t.Skip("whole-file and byte-range locks cannot be mixed")
+	}
+
+	path := filepath.Join(t.TempDir(), "ranges.lock")
+
+	f := New(path, SetFlag(os.O_CREATE|os.O_RDWR))
+
+	h, err := f.Acquire(Exclusive)
+	require.NoError(t, err)
+
+	err = f.LockRange(100, 10)
+	require.NoError(t, err)
+
+	// releasing the whole-file lock keeps the byte-range locks.
+	err = h.Release()
+	require.NoError(t, err)
+	assert.False(t, f.Locked())
+	assert.Equal(t, []Range{{Offset: 100, Length: 10, Exclusive: true}}, f.Ranges())
+
+	contender := New(path, SetFlag(os.O_CREATE|os.O_RDWR))
+
+	locked, err := contender.TryLock()
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	err = contender.Unlock()
+	require.NoError(t, err)
+
+	// the file descriptor holding the byte-range locks is still open.
+	assert.NotNil(t, f.File())
+
+	err = f.Unlock()
+	require.NoError(t, err)
+	assert.Empty(t, f.Ranges())
+}
+
+func TestFlock_fairKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fair.lock")
+
+	key := New(path).fairKey()
+
+	// the key does not change when the file is created.
+	err := os.WriteFile(path, nil, 0o600)
+	require.NoError(t, err)
+
+	assert.Equal(t, key, New(path).fairKey())
+	assert.Equal(t, key, New(filepath.Join(dir, "sub", "..", "fair.lock")).fairKey())
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+
+	err = os.Symlink(dir, link)
+	require.NoError(t, err)
+
+	assert.Equal(t, key, New(filepath.Join(link, "fair.lock")).fairKey())
+}
+
+func TestFlock_Break_replaced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+
+	err := os.WriteFile(path, nil, 0o600)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	err = os.Chtimes(path, old, old)
+	require.NoError(t, err)
+
+	// another contender breaks the stale file, and a live lock file appears before this one locks it.
+	holder := New(path)
+
+	testHookBreak = func() {
+		assert.NoError(t, os.Remove(path))
+		assert.NoError(t, os.WriteFile(path, nil, 0o600))
+	}
+
+	t.Cleanup(func() { testHookBreak = func() {} })
+
+	broken, err := New(path).Break(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, broken)
+
+	assert.FileExists(t, path)
+
+	// a lock file held by another *Flock is not removed either, even if it looks stale.
+	err = os.Chtimes(path, old, old)
+	require.NoError(t, err)
+
+	testHookBreak = func() {
+		assert.NoError(t, holder.Lock())
+	}
+
+	broken, err = New(path).Break(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, broken)
+	assert.FileExists(t, path)
+
+	valid, err := holder.Valid()
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+}
+
+func Test_records(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.lock")
+
+	f := New(path, SetFlag(os.O_CREATE|os.O_RDWR))
+	g := New(path, SetFlag(os.O_CREATE|os.O_RDWR))
+
+	for _, h := range []*Flock{f, g} {
+		err := h.setFh(h.flag)
+		require.NoError(t, err)
+
+		t.Cleanup(h.resetFh)
+	}
+
+	reserve := func(h *Flock, r Range, wait bool) bool {
+		ok, err := h.reserveRange(r, wait)
+		require.NoError(t, err)
+
+		if ok {
+			h.ranges = setRange(h.ranges, r, mergeRanges)
+			h.syncRecords()
+		}
+
+		return ok
+	}
+
+	assert.True(t, reserve(f, Range{Offset: 0, Length: 10, Exclusive: true}, false))
+	assert.False(t, reserve(g, Range{Offset: 5, Length: 10}, false))
+	assert.True(t, reserve(g, Range{Offset: 10, Length: 10}, false))
+
+	// only the bytes that no other *Flock holds are released by the OS.
+	assert.Equal(t, []Range{{Offset: 0, Length: 10}, {Offset: 20}}, f.freeRanges(Range{}))
+	assert.Equal(t, []Range{{Offset: 10, Length: 10}}, g.freeRanges(Range{Offset: 10, Length: 10}))
+
+	// a descriptor closed while ranges are held is closed with the last range.
+	fh, err := os.Open(path)
+	require.NoError(t, err)
+	assert.True(t, parkFile(fh))
+
+	// a waiter is woken up when the conflicting range is released.
+	done := make(chan bool)
+
+	go func() {
+		ok, _ := g.reserveRange(Range{Offset: 0, Length: 5, Exclusive: true}, true)
+		done <- ok
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("reserveRange returned while the range was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.ranges = nil
+	f.syncRecords()
+
+	assert.True(t, <-done)
+
+	g.ranges = nil
+	g.syncRecords()
+
+	assert.Empty(t, records)
+	require.ErrorIs(t, fh.Close(), os.ErrClosed)
+}
+
+func TestFlock_LockContext_background(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "background.lock")
+
+	holder := New(path)
+
+	err := holder.Lock()
+	require.NoError(t, err)
+
+	f := New(path, RecordOwner("background"), RemoveOnUnlock())
+
+	var p *pendingLock
+
+	// the call left in the background is reused by the next calls.
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+
+		err = f.LockContext(ctx)
+
+		cancel()
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotNil(t, f.pending)
+
+		if p != nil {
+			assert.Same(t, p, f.pending)
+		}
+
+		p = f.pending
+	}
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	<-p.done
+
+	// the abandoned lock is released without the side effects of the options.
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	_, err = ReadOwner(path)
+	require.ErrorIs(t, err, ErrNoOwner)
+
+	locked, err := holder.TryLock()
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	// the options apply once the lock is handed over.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = f.LockContext(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, f.pending)
+
+	o, err := f.Owner()
+	require.NoError(t, err)
+	assert.Equal(t, "background", o.Description)
+
+	err = f.Unlock()
+	require.NoError(t, err)
+
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLease_TryAcquire_expired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+
+	holder := NewLease(path, 50*time.Millisecond)
+
+	acquired, err := holder.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// the lease expires before the timer reports it.
+	holder.timer.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	contender := NewLease(path, time.Hour)
+
+	acquired, err = contender.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = holder.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	select {
+	case <-holder.Lost():
+	default:
+		t.Fatal("the loss of the lease was not reported")
+	}
+
+	err = contender.Release()
+	require.NoError(t, err)
+}
+
+func Test_processStartTime(t *testing.T) {
+	start, err := processStartTime(os.Getpid())
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("the start time of a process cannot be read")
+	}
+
+	require.NoError(t, err)
+	assert.Equal(t, start, processStart)
+
+	// the process started before the tests, which cannot take that long.
+	assert.True(t, start.Before(time.Now()))
+	assert.WithinDuration(t, time.Now(), start, time.Hour)
+
+	_, err = processStartTime(-1)
+	require.Error(t, err)
+}
@@ -0,0 +1,356 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrLeaseLost is returned when renewing or releasing a lease that expired or was taken over.
+var ErrLeaseLost = errors.New("lease lost")
+
+// leaseVersion is the version of the lease record written in the lock file.
+const leaseVersion = 1
+
+// leaseRecord is the content of the lock file of a lease.
+type leaseRecord struct {
+	Version int       `json:"version"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Lease is a time-bounded lock.
+// The holder records an expiry in the lock file and must call Renew before it lapses:
+// once the lease has expired, any contender may take it over,
+// even if the holder is still alive (but hung).
+//
+// The file lock is only held while the lease record is read or written,
+// so a hung holder does not block the contenders.
+// Expiry times are compared across processes, so the clocks of the hosts sharing the lock file must be synchronized.
+type Lease struct {
+	f   *Flock
+	ttl time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	timer   *time.Timer
+	lost    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	// dropped is true once the lease is lost, until it is acquired again.
+	dropped bool
+}
+
+// NewLease returns a new instance of *Lease for the lock file at path,
+// whose holder must renew it within ttl.
+// The options are applied to the underlying *Flock,
+// which is always opened in read-write mode.
+// The RecordOwner, RemoveOnUnlock, and Heartbeat options are ignored:
+// the lease record is the content of the lock file, which must outlive the short file locks taken by the lease.
+func NewLease(path string, ttl time.Duration, opts ...Option) *Lease {
+	lost := make(chan struct{})
+	close(lost)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(ErrNotLocked)
+
+	f := New(path, append(slices.Clip(opts), SetFlag(os.O_CREATE|os.O_RDWR))...)
+	f.recordOwner, f.description = false, ""
+	f.removeOnUnlock = false
+	f.heartbeat = 0
+
+	return &Lease{
+		f:      f,
+		ttl:    ttl,
+		lost:   lost,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Path returns the path of the lock file.
+func (l *Lease) Path() string {
+	return l.f.Path()
+}
+
+// TryAcquire tries to take the lease without blocking.
+// It returns false if the lease is held by someone else and has not expired yet.
+func (l *Lease) TryAcquire() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token != "" {
+		if time.Now().Before(l.expires) {
+			return true, nil
+		}
+
+		// The lease expired before the timer reported it.
+		l.drop(ErrLeaseLost)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return false, err
+	}
+
+	var expires time.Time
+
+	err = l.update(func(rec *leaseRecord, now time.Time) (*leaseRecord, error) {
+		if rec != nil && now.Before(rec.Expires) {
+			return nil, nil
+		}
+
+		expires = now.Add(l.ttl)
+
+		return &leaseRecord{Version: leaseVersion, Token: token, Expires: expires}, nil
+	})
+	if err != nil || expires.IsZero() {
+		return false, err
+	}
+
+	l.token = token
+	l.expires = expires
+	l.dropped = false
+	l.lost = make(chan struct{})
+	l.ctx, l.cancel = context.WithCancelCause(context.Background())
+	l.schedule()
+
+	return true, nil
+}
+
+// TryAcquireContext repeatedly tries to take the lease until one of the conditions is met:
+// - TryAcquire succeeds
+// - TryAcquire fails with error
+// - Context Done channel is closed.
+func (l *Lease) TryAcquireContext(ctx context.Context, retryDelay time.Duration) (bool, error) {
+	return retry(ctx, l.TryAcquire, RetryPolicy{Backoff: ConstantBackoff(retryDelay)})
+}
+
+// Renew extends the lease by its ttl from now.
+// It returns ErrLeaseLost if the lease has expired or has been taken over,
+// in which case the holder must stop working under the lease.
+func (l *Lease) Renew() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		return &fs.PathError{Op: "Renew", Path: l.Path(), Err: ErrLeaseLost}
+	}
+
+	var expires time.Time
+
+	err := l.update(func(rec *leaseRecord, now time.Time) (*leaseRecord, error) {
+		if rec == nil || rec.Token != l.token || !now.Before(rec.Expires) {
+			return nil, ErrLeaseLost
+		}
+
+		expires = now.Add(l.ttl)
+
+		return &leaseRecord{Version: leaseVersion, Token: l.token, Expires: expires}, nil
+	})
+
+	if errors.Is(err, ErrLeaseLost) {
+		l.drop(ErrLeaseLost)
+
+		return &fs.PathError{Op: "Renew", Path: l.Path(), Err: ErrLeaseLost}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	l.expires = expires
+	l.timer.Reset(time.Until(expires))
+
+	return nil
+}
+
+// Release gives up the lease and clears the lease record,
+// so that contenders can take it immediately.
+// It returns ErrLeaseLost if the lease had already been lost.
+func (l *Lease) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		if l.dropped {
+			return &fs.PathError{Op: "Release", Path: l.Path(), Err: ErrLeaseLost}
+		}
+
+		return nil
+	}
+
+	var lost bool
+
+	err := l.update(func(rec *leaseRecord, _ time.Time) (*leaseRecord, error) {
+		if rec == nil || rec.Token != l.token {
+			lost = true
+			return nil, nil
+		}
+
+		return &leaseRecord{}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if lost {
+		l.drop(ErrLeaseLost)
+
+		return &fs.PathError{Op: "Release", Path: l.Path(), Err: ErrLeaseLost}
+	}
+
+	l.token = ""
+	l.timer.Stop()
+	l.cancel(ErrNotLocked)
+
+	return nil
+}
+
+// Held reports whether the lease is held and has not expired.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func (l *Lease) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.token != "" && time.Now().Before(l.expires)
+}
+
+// Expires returns the expiry of the lease, as of the last acquisition or renewal.
+func (l *Lease) Expires() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.expires
+}
+
+// Lost returns a channel that is closed when the lease is lost:
+// it expired without being renewed, or was taken over.
+// Each acquisition has its own channel, which is already closed while the lease is not held.
+func (l *Lease) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lost
+}
+
+// Context returns a context that is canceled when the lease is lost or released.
+// context.Cause returns ErrLeaseLost if the lease was lost.
+// Each acquisition has its own context, which is already canceled while the lease is not held.
+func (l *Lease) Context() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ctx
+}
+
+// schedule reports the loss of the lease if it is not renewed before it expires.
+func (l *Lease) schedule() {
+	l.timer = time.AfterFunc(time.Until(l.expires), func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.token != "" && !time.Now().Before(l.expires) {
+			l.drop(ErrLeaseLost)
+		}
+	})
+}
+
+// drop marks the lease as lost.
+func (l *Lease) drop(cause error) {
+	l.token = ""
+	l.dropped = true
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+
+	close(l.lost)
+	l.cancel(cause)
+}
+
+// update reads the lease record under an exclusive file lock,
+// and writes the record returned by fn, if any.
+func (l *Lease) update(fn func(rec *leaseRecord, now time.Time) (*leaseRecord, error)) error {
+	if err := l.f.Lock(); err != nil {
+		return err
+	}
+
+	defer func() { _ = l.f.Unlock() }()
+
+	fh := l.f.fh
+
+	data, err := io.ReadAll(io.NewSectionReader(fh, 0, math.MaxInt64))
+	if err != nil {
+		return err
+	}
+
+	var rec *leaseRecord
+
+	if len(data) > 0 {
+		rec = &leaseRecord{}
+
+		if err := json.Unmarshal(data, rec); err != nil {
+			return &fs.PathError{Op: "read lease", Path: l.Path(), Err: err}
+		}
+
+		if rec.Token == "" {
+			rec = nil
+		}
+	}
+
+	next, err := fn(rec, time.Now())
+	if err != nil || next == nil {
+		return err
+	}
+
+	data = nil
+
+	if next.Token != "" {
+		data, err = json.Marshal(next)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rewrite(fh, data)
+}
+
+// rewrite replaces the content of the file with data.
+func rewrite(fh *os.File, data []byte) error {
+	if _, err := fh.WriteAt(data, 0); err != nil {
+		return err
+	}
+
+	if err := fh.Truncate(int64(len(data))); err != nil {
+		return err
+	}
+
+	return fh.Sync()
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
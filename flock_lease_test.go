@@ -0,0 +1,130 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+
+	holder := flock.NewLease(path, time.Hour)
+
+	acquired, err := holder.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+	assert.True(t, holder.Held())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), holder.Expires(), time.Second)
+
+	err = holder.Renew()
+	require.NoError(t, err)
+
+	contender := flock.NewLease(path, time.Hour)
+
+	acquired, err = contender.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	err = holder.Release()
+	require.NoError(t, err)
+	assert.False(t, holder.Held())
+	require.ErrorIs(t, holder.Context().Err(), context.Canceled)
+	require.NotErrorIs(t, context.Cause(holder.Context()), flock.ErrLeaseLost)
+
+	acquired, err = contender.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	err = contender.Release()
+	require.NoError(t, err)
+}
+
+func TestLease_expired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+
+	holder := flock.NewLease(path, 50*time.Millisecond)
+
+	acquired, err := holder.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	lost := holder.Lost()
+
+	// the contender takes over once the lease has expired, although the holder is still alive.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	contender := flock.NewLease(path, time.Hour)
+
+	acquired, err = contender.TryAcquireContext(ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	select {
+	case <-lost:
+	case <-ctx.Done():
+		t.Fatal("the loss of the lease was not reported")
+	}
+
+	require.ErrorIs(t, context.Cause(holder.Context()), flock.ErrLeaseLost)
+
+	err = holder.Renew()
+	require.ErrorIs(t, err, flock.ErrLeaseLost)
+
+	err = holder.Release()
+	require.ErrorIs(t, err, flock.ErrLeaseLost)
+	assert.False(t, holder.Held())
+
+	err = contender.Renew()
+	require.NoError(t, err)
+
+	err = contender.Release()
+	require.NoError(t, err)
+}
+
+func TestLease_options(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.lock")
+
+	// the options that would overwrite or remove the lease record are ignored.
+	opts := []flock.Option{flock.RecordOwner("lease"), flock.RemoveOnUnlock(), flock.Heartbeat(time.Millisecond)}
+
+	holder := flock.NewLease(path, time.Hour, opts...)
+
+	acquired, err := holder.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"token"`)
+
+	contender := flock.NewLease(path, time.Hour, opts...)
+
+	acquired, err = contender.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	err = holder.Release()
+	require.NoError(t, err)
+
+	// the options of the caller are left alone.
+	opts = make([]flock.Option, 1, 2)
+	opts[0] = flock.RecordOwner("lease")
+
+	_ = flock.NewLease(path, time.Hour, opts...)
+	assert.Nil(t, opts[:2][1])
+}
@@ -0,0 +1,118 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package flock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_parseProcLocks(t *testing.T) {
+	const procLocks = `1: FLOCK  ADVISORY  READ 10710 fe:00:9619138 0 EOF
+1: -> FLOCK  ADVISORY  WRITE 10713 fe:00:9619138 0 EOF
+2: POSIX  ADVISORY  WRITE 10714 fe:00:9619138 10 19
+3: OFDLCK ADVISORY  READ  -1 fe:00:9619138 20 EOF
+4: POSIX  ADVISORY  WRITE 10715 fe:01:9619138 0 EOF
+5: LEASE  ACTIVE    BREAKING  READ 10716 fe:00:9619138 0 EOF
+`
+
+	id := fileID{dev: unix.Mkdev(0xfe, 0), ino: 9619138}
+
+	holders, err := parseProcLocks(strings.NewReader(procLocks), id)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Holder{
+		{PID: 10710, Kind: KindFlock, Mode: Shared, Range: Range{}},
+		{PID: 10713, Kind: KindFlock, Mode: Exclusive, Range: Range{Exclusive: true}, Blocked: true},
+		{PID: 10714, Kind: KindPOSIX, Mode: Exclusive, Range: Range{Offset: 10, Length: 10, Exclusive: true}},
+		{PID: -1, Kind: KindOFD, Mode: Shared, Range: Range{Offset: 20}},
+	}, holders)
+}
+
+func TestProbe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probe.lock")
+
+	_, err := Probe(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.NoFileExists(t, path)
+
+	fh, err := os.Create(path)
+	require.NoError(t, err)
+
+	defer func() { _ = fh.Close() }()
+
+	st, err := Probe(path)
+	require.NoError(t, err)
+	assert.Equal(t, Status{Mode: Unlocked}, st)
+
+	// a lock taken behind the back of the registry is reported by the kernel.
+	err = unix.Flock(int(fh.Fd()), unix.LOCK_SH)
+	require.NoError(t, err)
+
+	st, err = Probe(path)
+	require.NoError(t, err)
+	assert.Equal(t, Status{Mode: Shared, PID: os.Getpid()}, st)
+
+	err = unix.Flock(int(fh.Fd()), unix.LOCK_UN)
+	require.NoError(t, err)
+
+	f := New(path)
+
+	err = f.Lock()
+	require.NoError(t, err)
+
+	st, err = Probe(path)
+	require.NoError(t, err)
+	assert.Equal(t, Status{Mode: Exclusive, PID: os.Getpid()}, st)
+
+	err = f.Unlock()
+	require.NoError(t, err)
+}
+
+func TestUseOFDLocks_mixed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ofd.lock")
+
+	f := New(path, UseOFDLocks(), SetFlag(os.O_CREATE|os.O_RDWR))
+
+	err := f.Lock()
+	require.NoError(t, err)
+
+	err = f.LockRange(0, 10)
+	require.ErrorIs(t, err, errRecordLockMixed)
+
+	// the whole-file lock still covers the range.
+	contender := New(path, UseOFDLocks(), SetFlag(os.O_CREATE|os.O_RDWR))
+
+	locked, err := contender.TryLockRange(0, 10)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	err = f.Unlock()
+	require.NoError(t, err)
+
+	err = f.LockRange(0, 10)
+	require.NoError(t, err)
+
+	err = f.Lock()
+	require.ErrorIs(t, err, errRecordLockMixed)
+	assert.False(t, f.Locked())
+	assert.Equal(t, []Range{{Offset: 0, Length: 10, Exclusive: true}}, f.Ranges())
+
+	locked, err = contender.TryLockRange(5, 1)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	err = f.UnlockRange(0, 0)
+	require.NoError(t, err)
+}
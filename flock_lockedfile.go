@@ -0,0 +1,186 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// LockedFile is a file that is locked while it is open:
+// a shared lock if it is opened for reading only, an exclusive lock otherwise.
+// It is modeled on the lockedfile package of the Go command (cmd/go/internal/lockedfile).
+//
+// The lock is taken on the file itself,
+// so every process accessing the file must go through LockedFile (or a *Flock on the same path).
+// On Windows, where locks are mandatory, the first byte of the file cannot be read or written by other processes
+// while it is locked.
+type LockedFile struct {
+	*os.File
+
+	f      *Flock
+	closed bool
+}
+
+// Open opens the named file for reading, and takes a shared lock on it.
+// It blocks until the lock is available.
+func Open(name string) (*LockedFile, error) {
+	return OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file, and takes an exclusive lock on it.
+// It blocks until the lock is available.
+// The file is truncated once the lock is held, not while another process may be reading it.
+func Create(name string) (*LockedFile, error) {
+	return OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// OpenFile is like os.OpenFile, but also locks the file:
+// with a shared lock if flag opens it for reading only, with an exclusive lock otherwise.
+// It blocks until the lock is available.
+// If flag includes os.O_TRUNC, the file is truncated once the lock is held.
+func OpenFile(name string, flag int, perm fs.FileMode) (*LockedFile, error) {
+	if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		// The file must be created by this call, but it is only opened by the *Flock when locking.
+		fh, err := os.OpenFile(name, flag&^os.O_TRUNC, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = fh.Close()
+	}
+
+	f := New(name, SetFlag(flag&^(os.O_TRUNC|os.O_EXCL)), SetPermissions(perm))
+
+	var err error
+
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		err = f.RLock()
+	} else {
+		err = f.Lock()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	fh := f.File()
+
+	if flag&os.O_TRUNC != 0 {
+		if err := fh.Truncate(0); err != nil {
+			_ = f.Close()
+
+			return nil, err
+		}
+	}
+
+	return &LockedFile{File: fh, f: f}, nil
+}
+
+// Close unlocks and closes the file.
+func (l *LockedFile) Close() error {
+	if l.closed {
+		return &fs.PathError{Op: "close", Path: l.Name(), Err: fs.ErrClosed}
+	}
+
+	l.closed = true
+
+	return l.f.Close()
+}
+
+// ReadFile is like os.ReadFile, but reads the file under a shared lock.
+func ReadFile(name string) ([]byte, error) {
+	l, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = l.Close() }()
+
+	return io.ReadAll(l)
+}
+
+// WriteFile is like os.WriteFile, but writes the file under an exclusive lock.
+// The file is truncated once the lock is held, not while another process may be reading it.
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	l, err := OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.Write(data)
+
+	if closeErr := l.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Transform replaces the content of the named file with the result of fn applied to its current content,
+// under an exclusive lock.
+// The file must exist.
+//
+// If fn returns an error, the file is left unchanged.
+// The new content is written in place and synced to disk;
+// if writing fails, the previous content is restored on a best-effort basis.
+func Transform(name string, fn func(old []byte) ([]byte, error)) (err error) {
+	l, err := OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := l.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	old, err := io.ReadAll(l)
+	if err != nil {
+		return err
+	}
+
+	data, err := fn(old)
+	if err != nil {
+		return err
+	}
+
+	if len(data) > len(old) {
+		// Write the tail first: if the disk is full, the old content is still intact.
+		if _, err := l.WriteAt(data[len(old):], int64(len(old))); err != nil {
+			_ = l.Truncate(int64(len(old)))
+
+			return err
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			if _, err := l.WriteAt(old, 0); err == nil {
+				_ = l.Truncate(int64(len(old)))
+			}
+		}
+	}()
+
+	if len(data) >= len(old) {
+		if _, err := l.WriteAt(data[:len(old)], 0); err != nil {
+			return err
+		}
+	} else {
+		if _, err := l.WriteAt(data, 0); err != nil {
+			return err
+		}
+
+		// Shrink the file after writing, so that the space of the old content is still reserved if writing fails.
+		if err := l.Truncate(int64(len(data))); err != nil {
+			return err
+		}
+	}
+
+	return l.Sync()
+}
@@ -0,0 +1,159 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	_, err := flock.Open(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = flock.WriteFile(path, []byte(`{"version":1}`), 0o600)
+	require.NoError(t, err)
+
+	data, err := flock.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":1}`, string(data))
+
+	reader, err := flock.Open(path)
+	require.NoError(t, err)
+
+	contender := flock.New(path)
+
+	locked, err := contender.TryLock()
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	// the file is not truncated until the shared lock is released.
+	done := make(chan error)
+
+	go func() {
+		done <- flock.WriteFile(path, []byte(`{"version":2}`), 0o600)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("WriteFile returned while the file was locked: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	data, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":1}`, string(data))
+
+	err = reader.Close()
+	require.NoError(t, err)
+
+	require.NoError(t, <-done)
+
+	err = reader.Close()
+	require.ErrorIs(t, err, os.ErrClosed)
+
+	data, err = flock.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":2}`, string(data))
+}
+
+func TestLockedFile_Create(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+
+	writer, err := flock.Create(path)
+	require.NoError(t, err)
+
+	_, err = writer.WriteString("42")
+	require.NoError(t, err)
+
+	contender := flock.New(path)
+
+	locked, err := contender.TryRLock()
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	err = writer.Close()
+	require.NoError(t, err)
+
+	_, err = flock.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	locked, err = contender.TryRLock()
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	err = contender.Unlock()
+	require.NoError(t, err)
+}
+
+func TestTransform(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+
+	err := flock.Transform(path, func(old []byte) ([]byte, error) { return old, nil })
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = flock.WriteFile(path, []byte("0"), 0o600)
+	require.NoError(t, err)
+
+	increment := func(old []byte) ([]byte, error) {
+		n, err := strconv.Atoi(string(old))
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, flock.Transform(path, increment))
+		}()
+	}
+
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "20", string(data))
+
+	// the file is truncated when the content shrinks.
+	err = flock.Transform(path, func([]byte) ([]byte, error) { return []byte("1"), nil })
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(data))
+
+	// the file is unchanged when fn fails.
+	errAbort := errors.New("abort")
+
+	err = flock.Transform(path, func([]byte) ([]byte, error) { return []byte("garbage"), errAbort })
+	require.ErrorIs(t, err, errAbort)
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(data))
+}
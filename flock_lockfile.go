@@ -0,0 +1,97 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"io/fs"
+	"os"
+)
+
+// lockfileSuffix is appended to the path of the target of a *Lockfile.
+const lockfileSuffix = ".lock"
+
+// Lockfile replaces a file atomically, following the convention of git (index.lock):
+// the new content is written to the lock file, named after the target with a .lock suffix,
+// which is renamed over the target on Commit, or removed on Rollback.
+//
+// The lock file is created exclusively, so its existence is the lock:
+// it interoperates with the other tools following the same convention, and works on any file system,
+// but a lock file left behind by a process that crashed must be removed by hand.
+// Readers of the target do not need any lock, as they see either the old or the new content.
+type Lockfile struct {
+	*os.File
+
+	target string
+	done   bool
+}
+
+// AcquireLockfile creates the lock file of target, with the given permissions, and opens it for writing.
+// It fails with an error wrapping fs.ErrExist if the lock file exists, i.e. if the target is locked.
+func AcquireLockfile(target string, perm fs.FileMode) (*Lockfile, error) {
+	fh, err := os.OpenFile(target+lockfileSuffix, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lockfile{File: fh, target: target}, nil
+}
+
+// Target returns the path of the file replaced by the lock file.
+func (l *Lockfile) Target() string {
+	return l.target
+}
+
+// Commit syncs the lock file to disk, and renames it over the target, which releases the lock.
+// If it fails, the lock file is removed and the target is left unchanged.
+func (l *Lockfile) Commit() error {
+	if l.done {
+		return &fs.PathError{Op: "Commit", Path: l.Name(), Err: fs.ErrClosed}
+	}
+
+	l.done = true
+
+	err := l.File.Sync()
+
+	if closeErr := l.File.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(l.Name(), l.target)
+	}
+
+	if err != nil {
+		_ = os.Remove(l.Name())
+
+		return err
+	}
+
+	return nil
+}
+
+// Rollback removes the lock file, which releases the lock and leaves the target unchanged.
+// It does nothing if the lock file was already committed or rolled back.
+func (l *Lockfile) Rollback() error {
+	if l.done {
+		return nil
+	}
+
+	l.done = true
+
+	err := l.File.Close()
+
+	if removeErr := os.Remove(l.Name()); err == nil {
+		err = removeErr
+	}
+
+	return err
+}
+
+// Close is equivalent to calling Rollback,
+// so that a deferred Close releases the lock if the lock file is not committed.
+func (l *Lockfile) Close() error {
+	return l.Rollback()
+}
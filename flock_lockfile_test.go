@@ -0,0 +1,82 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockfile(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "config")
+
+	err := os.WriteFile(target, []byte("old"), 0o600)
+	require.NoError(t, err)
+
+	l, err := flock.AcquireLockfile(target, 0o600)
+	require.NoError(t, err)
+	assert.Equal(t, target, l.Target())
+	assert.Equal(t, target+".lock", l.Name())
+
+	_, err = flock.AcquireLockfile(target, 0o600)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	_, err = l.WriteString("new")
+	require.NoError(t, err)
+
+	// the target is unchanged until the lock file is committed.
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+
+	err = l.Commit()
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	assert.NoFileExists(t, target+".lock")
+
+	err = l.Commit()
+	require.ErrorIs(t, err, os.ErrClosed)
+
+	// closing a committed lock file does nothing.
+	err = l.Close()
+	require.NoError(t, err)
+
+	assert.FileExists(t, target)
+}
+
+func TestLockfile_Rollback(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "config")
+
+	l, err := flock.AcquireLockfile(target, 0o600)
+	require.NoError(t, err)
+
+	_, err = l.WriteString("discarded")
+	require.NoError(t, err)
+
+	err = l.Rollback()
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, target)
+	assert.NoFileExists(t, target+".lock")
+
+	l, err = flock.AcquireLockfile(target, 0o600)
+	require.NoError(t, err)
+
+	err = l.Close()
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, target+".lock")
+}
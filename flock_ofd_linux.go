@@ -0,0 +1,18 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package flock
+
+import "golang.org/x/sys/unix"
+
+// Open file description locks are available since Linux 3.15.
+const ofdAvailable = true
+
+const (
+	fOFDSetlk  = unix.F_OFD_SETLK
+	fOFDSetlkw = unix.F_OFD_SETLKW
+)
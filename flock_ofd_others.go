@@ -0,0 +1,19 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || netbsd || openbsd || solaris
+
+package flock
+
+import "golang.org/x/sys/unix"
+
+// Open file description locks are Linux-specific:
+// UseOFDLocks falls back to the default backend.
+const ofdAvailable = false
+
+const (
+	fOFDSetlk  = unix.F_SETLK
+	fOFDSetlkw = unix.F_SETLKW
+)
@@ -36,6 +36,14 @@ func (f *Flock) Unlock() error {
 	}
 }
 
+func (f *Flock) unlock() error {
+	return f.Unlock()
+}
+
+func (f *Flock) unlockFile() error {
+	return f.Unlock()
+}
+
 func (f *Flock) TryLock() (bool, error) {
 	return false, f.Lock()
 }
@@ -43,3 +51,35 @@ func (f *Flock) TryLock() (bool, error) {
 func (f *Flock) TryRLock() (bool, error) {
 	return false, f.RLock()
 }
+
+const mergeRanges = true
+
+const processRecordLocks = false
+
+func (f *Flock) lockRegion(_ Range, _ bool) (bool, error) {
+	return false, &fs.PathError{
+		Op:   "LockRange",
+		Path: f.Path(),
+		Err:  errors.ErrUnsupported,
+	}
+}
+
+func (f *Flock) unlockRegion(_ Range) error {
+	return &fs.PathError{
+		Op:   "UnlockRange",
+		Path: f.Path(),
+		Err:  errors.ErrUnsupported,
+	}
+}
+
+// adoptOwner transfers the lock bookkeeping of g to f.
+// The lock belongs to the file descriptor, so there is nothing to transfer.
+func (f *Flock) adoptOwner(_ *Flock) {}
+
+func (f *Flock) convertLock(_, _ bool) (bool, bool, error) {
+	return false, false, &fs.PathError{
+		Op:   "Upgrade",
+		Path: f.Path(),
+		Err:  errors.ErrUnsupported,
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"time"
+)
+
+// ErrNoOwner is returned when the lock file does not contain an owner record.
+var ErrNoOwner = errors.New("no owner record")
+
+var errOwnerVersion = errors.New("unsupported owner record version")
+
+// ownerVersion is the version of the owner record written in the lock file.
+const ownerVersion = 1
+
+// ownerOffset is the offset of the owner record in the lock file.
+// The first byte is locked by the whole-file lock on Windows, where locks are mandatory,
+// so it is left out of the record for contenders to be able to read it.
+const ownerOffset = 1
+
+// processStart is the start time of the process,
+// or the time the package was initialized, close to it, where it cannot be read.
+var processStart = func() time.Time {
+	if t, err := processStartTime(os.Getpid()); err == nil {
+		return t
+	}
+
+	return time.Now()
+}()
+
+// Owner describes the holder of an exclusive lock, as recorded in the lock file by the RecordOwner option.
+type Owner struct {
+	Version     int       `json:"version"`
+	PID         int       `json:"pid"`
+	Hostname    string    `json:"hostname"`
+	Started     time.Time `json:"started"`
+	Executable  string    `json:"executable"`
+	Description string    `json:"description,omitempty"`
+}
+
+func (o *Owner) String() string {
+	s := fmt.Sprintf("pid %d on %s (%s)", o.PID, o.Hostname, o.Executable)
+	if o.Description != "" {
+		s += ": " + o.Description
+	}
+
+	return s
+}
+
+// RecordOwner makes the *Flock write an owner record in the lock file each time it takes an exclusive lock:
+// the PID, hostname, start time (approximately), and executable of the process,
+// and the given description.
+// The record is cleared before the exclusive lock is released or downgraded.
+// Contenders can read it with Owner or ReadOwner.
+//
+// The lock file is always opened in read-write mode,
+// so this option cannot be used with a directory.
+// The record is written on a best-effort basis: failing to write it does not fail the lock.
+func RecordOwner(description string) Option {
+	return func(f *Flock) {
+		f.recordOwner = true
+		f.description = description
+	}
+}
+
+// Owner returns the owner record of the lock file.
+// It returns ErrNoOwner if the file contains no record,
+// which is the case when the exclusive lock is not held by a *Flock using the RecordOwner option.
+func (f *Flock) Owner() (*Owner, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	if f.fh != nil {
+		return parseOwner(f.path, f.fh)
+	}
+
+	return ReadOwner(f.path)
+}
+
+// ReadOwner returns the owner record of the lock file at path, without locking it.
+// It returns ErrNoOwner if the file contains no record.
+//
+// The record may be read while it is being written, in which case an error is returned.
+func ReadOwner(path string) (*Owner, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = fh.Close() }()
+
+	return parseOwner(path, fh)
+}
+
+func parseOwner(path string, fh *os.File) (*Owner, error) {
+	data, err := io.ReadAll(io.NewSectionReader(fh, ownerOffset, math.MaxInt64-ownerOffset))
+	if err != nil {
+		return nil, &fs.PathError{Op: "ReadOwner", Path: path, Err: err}
+	}
+
+	if len(data) == 0 {
+		return nil, &fs.PathError{Op: "ReadOwner", Path: path, Err: ErrNoOwner}
+	}
+
+	o := &Owner{}
+
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, &fs.PathError{Op: "ReadOwner", Path: path, Err: err}
+	}
+
+	if o.Version != ownerVersion {
+		return nil, &fs.PathError{Op: "ReadOwner", Path: path, Err: fmt.Errorf("%w %d", errOwnerVersion, o.Version)}
+	}
+
+	return o, nil
+}
+
+// writeOwner writes or clears the owner record, depending on whether the exclusive lock is held.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) writeOwner() {
+	if !f.recordOwner || f.fh == nil || f.l == f.ownerWritten {
+		return
+	}
+
+	if !f.l {
+		f.clearOwner()
+		return
+	}
+
+	data, err := json.Marshal(newOwner(f.description))
+	if err != nil {
+		return
+	}
+
+	// JSON ignores leading whitespace, so the record is still valid when read from the start of the file.
+	data = append([]byte{'\n'}, data...)
+
+	f.ownerWritten = rewrite(f.fh, data) == nil
+}
+
+// clearOwner clears the owner record before the exclusive lock is released.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) clearOwner() {
+	if !f.ownerWritten || f.fh == nil {
+		return
+	}
+
+	_ = rewrite(f.fh, nil)
+
+	f.ownerWritten = false
+}
+
+func newOwner(description string) *Owner {
+	o := &Owner{
+		Version:     ownerVersion,
+		PID:         os.Getpid(),
+		Started:     processStart,
+		Description: description,
+	}
+
+	o.Hostname, _ = os.Hostname()
+	o.Executable, _ = os.Executable()
+
+	return o
+}
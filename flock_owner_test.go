@@ -0,0 +1,66 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owner.lock")
+
+	holder := flock.New(path, flock.RecordOwner("nightly backup"))
+
+	_, err := flock.ReadOwner(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = holder.Lock()
+	require.NoError(t, err)
+
+	owner, err := flock.ReadOwner(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), owner.PID)
+	assert.Equal(t, "nightly backup", owner.Description)
+	assert.NotEmpty(t, owner.Executable)
+	assert.False(t, owner.Started.IsZero())
+
+	contender := flock.New(path)
+
+	locked, err := contender.TryLock()
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	owner, err = contender.Owner()
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), owner.PID)
+
+	_, err = holder.Downgrade()
+	require.NoError(t, err)
+
+	_, err = holder.Owner()
+	require.ErrorIs(t, err, flock.ErrNoOwner)
+
+	_, err = holder.Upgrade()
+	require.NoError(t, err)
+
+	owner, err = holder.Owner()
+	require.NoError(t, err)
+	assert.Equal(t, "nightly backup", owner.Description)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	_, err = flock.ReadOwner(path)
+	require.ErrorIs(t, err, flock.ErrNoOwner)
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// CreateParents makes the *Flock create the missing parent directories of the lock file
+// with the given permissions (before umask), when the file is created.
+//
+// Several processes may create the same directories at the same time:
+// a directory created by another process in between is used as is.
+func CreateParents(perm fs.FileMode) Option {
+	return func(f *Flock) {
+		f.createParents = true
+		f.parentPerm = perm
+	}
+}
+
+// ParentOwner sets the owner and group of the parent directories created by the CreateParents option.
+// A uid or gid of -1 keeps the owner or the group of the process.
+// Directories created by another process, and directories that already existed, are left unchanged.
+//
+// It has no effect on Windows.
+func ParentOwner(uid, gid int) Option {
+	return func(f *Flock) {
+		f.parentOwner = true
+		f.parentUID = uid
+		f.parentGID = gid
+	}
+}
+
+// openFile opens the lock file, creating its parent directories if needed with the CreateParents option.
+func (f *Flock) openFile(flag int) (*os.File, error) {
+	fh, err := os.OpenFile(f.path, flag, f.perm)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) || !f.createParents || flag&os.O_CREATE == 0 {
+		return fh, err
+	}
+
+	if err := f.mkdirParents(); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(f.path, flag, f.perm)
+}
+
+// mkdirParents creates the missing parent directories of the lock file, from the top.
+func (f *Flock) mkdirParents() error {
+	var missing []string
+
+	for dir := filepath.Dir(f.path); ; {
+		_, err := os.Stat(dir)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		missing = append(missing, dir)
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+
+		dir = parent
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		dir := missing[i]
+
+		if err := os.Mkdir(dir, f.parentPerm); err != nil {
+			if fi, statErr := os.Stat(dir); statErr == nil && fi.IsDir() {
+				// Created by another process in the meantime.
+				continue
+			}
+
+			return err
+		}
+
+		if f.parentOwner && runtime.GOOS != "windows" {
+			if err := os.Chown(dir, f.parentUID, f.parentGID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
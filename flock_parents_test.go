@@ -0,0 +1,75 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateParents(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run", "app")
+	path := filepath.Join(dir, "locks", "x.lock")
+
+	locked, err := flock.New(path).TryLock()
+	require.ErrorIs(t, err, os.ErrNotExist)
+	assert.False(t, locked)
+
+	f := flock.New(path, flock.CreateParents(0o750), flock.ParentOwner(os.Getuid(), os.Getgid()))
+
+	locked, err = f.TryLock()
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	fi, err := os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o750), fi.Mode().Perm()&0o750)
+		assert.Zero(t, fi.Mode().Perm()&0o007)
+	}
+
+	err = f.Unlock()
+	require.NoError(t, err)
+}
+
+func TestCreateParents_concurrent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b", "c")
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			f := flock.New(filepath.Join(dir, strconv.Itoa(i)+".lock"), flock.CreateParents(0o755))
+
+			err := f.Lock()
+			if assert.NoError(t, err) {
+				assert.NoError(t, f.Unlock())
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 8)
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Status is the lock state of a file, as reported by Probe.
+type Status struct {
+	// Mode is the strongest lock held on the file.
+	Mode Mode
+	// PID is a process holding the lock, or 0 if unknown.
+	PID int
+}
+
+// Probe reports whether the file at path is locked, and how, without taking any lock.
+// It never creates the file, and is safe to call from monitoring code.
+//
+// Whole-file locks held by the current process are reported first.
+// Otherwise, Probe asks the OS:
+// on Linux, it reads /proc/locks, and reports flock(2), POSIX record, and open file description locks;
+// on other UNIX-like operating systems, it uses F_GETLK, which only reports locks held by other processes,
+// through a descriptor of a *Flock of the process if there is one:
+// the file is only opened (and closed, which releases the POSIX record locks of the process on it)
+// when the process does not have it open through a *Flock.
+// Byte-range locks are reported as locks on the file.
+// Probe returns an error wrapping errors.ErrUnsupported on other operating systems.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func Probe(path string) (Status, error) {
+	id, err := pathFileID(path)
+	if err != nil {
+		return Status{}, err
+	}
+
+	if mode := registryMode(id); mode != Unlocked {
+		return Status{Mode: mode, PID: os.Getpid()}, nil
+	}
+
+	st, err := probe(path, id)
+	if err != nil {
+		return Status{}, &fs.PathError{Op: "Probe", Path: path, Err: err}
+	}
+
+	return st, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package flock
+
+import "os"
+
+// trackFile does nothing: probe does not open the file.
+func trackFile(*os.File) {}
+
+// untrackFile does nothing: probe does not open the file.
+func untrackFile(*os.File) {}
+
+// probe returns the strongest lock on the file reported by /proc/locks.
+func probe(_ string, id fileID) (Status, error) {
+	holders, err := lockHolders(id)
+	if err != nil {
+		return Status{}, err
+	}
+
+	var st Status
+
+	for _, h := range holders {
+		if h.Blocked || h.Mode <= st.Mode {
+			continue
+		}
+
+		st.Mode = h.Mode
+		st.PID = max(h.PID, 0)
+	}
+
+	return st, nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris
+
+package flock
+
+import (
+	"errors"
+	"os"
+)
+
+func trackFile(*os.File) {}
+
+func untrackFile(*os.File) {}
+
+func probe(string, fileID) (Status, error) {
+	return Status{}, errors.ErrUnsupported
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// The descriptors opened by the *Flock values of the process, with the identity of their file.
+// Closing any descriptor of a file releases the POSIX record locks held by the process on it,
+// so probe uses one of them when there is one, and only opens the file otherwise.
+var (
+	probeMu    sync.Mutex
+	probeFiles = map[*os.File]fileID{}
+)
+
+// trackFile records a descriptor opened by a *Flock.
+func trackFile(fh *os.File) {
+	id, err := fileIDOf(fh)
+	if err != nil {
+		return
+	}
+
+	probeMu.Lock()
+	defer probeMu.Unlock()
+
+	probeFiles[fh] = id
+}
+
+// untrackFile forgets a descriptor, which must be called before closing it.
+func untrackFile(fh *os.File) {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+
+	delete(probeFiles, fh)
+}
+
+// probe returns the first lock held by another process that would conflict with an exclusive lock on the file.
+func probe(path string, id fileID) (Status, error) {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+
+	for fh, fid := range probeFiles {
+		if fid == id {
+			return getlk(fh)
+		}
+	}
+
+	// No *Flock of the process has the file open, so it holds no record lock on it.
+	fh, err := os.Open(path)
+	if err != nil {
+		return Status{}, err
+	}
+
+	defer func() { _ = fh.Close() }()
+
+	return getlk(fh)
+}
+
+func getlk(fh *os.File) (Status, error) {
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+
+	if err := unix.FcntlFlock(fh.Fd(), unix.F_GETLK, &lk); err != nil {
+		return Status{}, err
+	}
+
+	switch lk.Type {
+	case unix.F_RDLCK:
+		return Status{Mode: Shared, PID: max(int(lk.Pid), 0)}, nil
+	case unix.F_WRLCK:
+		return Status{Mode: Exclusive, PID: max(int(lk.Pid), 0)}, nil
+	default:
+		return Status{}, nil
+	}
+}
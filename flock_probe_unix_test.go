@@ -0,0 +1,39 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe_trackedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probe.lock")
+
+	f := New(path, SetFlag(os.O_CREATE|os.O_RDWR))
+
+	err := f.LockRange(0, 10)
+	require.NoError(t, err)
+
+	fh := f.File()
+	assert.Contains(t, probeFiles, fh)
+
+	// the descriptor of the *Flock is used, so the range lock is not released.
+	st, err := Probe(path)
+	require.NoError(t, err)
+	assert.Equal(t, Status{Mode: Unlocked}, st)
+	assert.Contains(t, probeFiles, fh)
+
+	err = f.Unlock()
+	require.NoError(t, err)
+	assert.NotContains(t, probeFiles, fh)
+}
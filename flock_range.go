@@ -0,0 +1,288 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"cmp"
+	"io/fs"
+	"math"
+	"slices"
+)
+
+// Range describes a byte-range lock held by a *Flock.
+type Range struct {
+	// Offset is the first byte of the range.
+	Offset int64
+	// Length is the number of bytes in the range.
+	// A zero length means the range extends to the end of the file,
+	// including bytes that are appended later.
+	Length int64
+	// Exclusive is true for an exclusive lock and false for a shared lock.
+	Exclusive bool
+}
+
+// end returns the first byte after the range.
+func (r Range) end() int64 {
+	if r.Length == 0 {
+		return math.MaxInt64
+	}
+
+	return r.Offset + r.Length
+}
+
+// newRange builds a Range from an offset and its end (as returned by Range.end).
+func newRange(offset, end int64, exclusive bool) Range {
+	r := Range{Offset: offset, Exclusive: exclusive}
+
+	if end != math.MaxInt64 {
+		r.Length = end - offset
+	}
+
+	return r
+}
+
+// validRange reports whether offset and length describe a lockable range.
+func validRange(offset, length int64) bool {
+	return offset >= 0 && length >= 0 && length <= math.MaxInt64-offset
+}
+
+// Ranges returns the byte ranges currently locked through LockRange, RLockRange,
+// TryLockRange, or TryRLockRange, sorted by offset.
+// Adjacent ranges with the same mode are reported as a single range,
+// except on Windows where each range is unlocked separately by the OS.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func (f *Flock) Ranges() []Range {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	return slices.Clone(f.ranges)
+}
+
+// LockRange is a blocking call to try and take an exclusive lock on length bytes starting at offset.
+// A zero length locks everything from offset to the end of the file, including bytes appended later.
+//
+// Locking a range that overlaps a range already held by the *Flock converts the overlapping part to the new mode.
+// Windows cannot convert a locked range:
+// locking a range that partially overlaps a held range, or that changes its mode, returns an error there.
+//
+// Range locks are POSIX record locks on UNIX-like operating systems.
+// On Linux, they are open file description locks owned by the file descriptor of the *Flock,
+// and are independent of the whole-file lock taken by Lock() or RLock().
+// On the other UNIX-like operating systems, they belong to the process, and may interact with the whole-file lock:
+// the *Flock values of the process track their ranges in a process-wide table,
+// so that they conflict with each other like with other processes,
+// and keep their file descriptors open while ranges are held on the file.
+// Closing a descriptor of the file opened by other means, such as os.Open, still releases the ranges of the process.
+//
+// With the UseOFDLocks option, and on AIX and Solaris, the whole-file lock is a record lock too,
+// on the same bytes as the range locks: range locks cannot be taken while it is held, and vice versa.
+// On AIX and Solaris, this also applies to the *Flock values of the process that lock the same file.
+// On Windows, the whole-file lock is a lock on the first byte of the file,
+// so it conflicts with range locks that include offset 0.
+func (f *Flock) LockRange(offset, length int64) error {
+	_, err := f.lockRange("LockRange", offset, length, true, true)
+	return err
+}
+
+// RLockRange is a blocking call to try and take a shared lock on length bytes starting at offset.
+// A zero length locks everything from offset to the end of the file, including bytes appended later.
+//
+// See LockRange() for more details.
+func (f *Flock) RLockRange(offset, length int64) error {
+	_, err := f.lockRange("RLockRange", offset, length, false, true)
+	return err
+}
+
+// TryLockRange tries to take an exclusive lock on length bytes starting at offset without blocking.
+// If the range is locked by someone else, the function returns false instead of waiting for the lock.
+//
+// See LockRange() for more details.
+func (f *Flock) TryLockRange(offset, length int64) (bool, error) {
+	return f.lockRange("TryLockRange", offset, length, true, false)
+}
+
+// TryRLockRange tries to take a shared lock on length bytes starting at offset without blocking.
+// If the range is exclusively locked by someone else, the function returns false instead of waiting for the lock.
+//
+// See LockRange() for more details.
+func (f *Flock) TryRLockRange(offset, length int64) (bool, error) {
+	return f.lockRange("TryRLockRange", offset, length, false, false)
+}
+
+// UnlockRange releases the lock on length bytes starting at offset.
+// A zero length unlocks everything from offset to the end of the file.
+//
+// Only the part of the held ranges that overlaps the given range is released:
+// a held range that extends past either end of the given range is split,
+// and the remaining parts stay locked.
+//
+// When the last range is released and no whole-file lock is held, the file descriptor is closed.
+func (f *Flock) UnlockRange(offset, length int64) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if !validRange(offset, length) {
+		return &fs.PathError{Op: "UnlockRange", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	r := Range{Offset: offset, Length: length}
+
+	if f.fh == nil || !overlaps(f.ranges, r) {
+		return nil
+	}
+
+	err := f.unlockRegion(r)
+	if err != nil {
+		return err
+	}
+
+	f.ranges = clearRange(f.ranges, r)
+
+	f.syncRecords()
+	f.ensureFhState()
+
+	return nil
+}
+
+func (f *Flock) lockRange(op string, offset, length int64, exclusive, wait bool) (bool, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if !validRange(offset, length) {
+		return false, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	r := Range{Offset: offset, Length: length, Exclusive: exclusive}
+
+	if covered(f.ranges, r) {
+		return true, nil
+	}
+
+	return f.verified(func() (bool, error) {
+		return f.lockRangeFh(r, wait)
+	})
+}
+
+// lockRangeFh opens the file if needed, and locks r.
+func (f *Flock) lockRangeFh(r Range, wait bool) (bool, error) {
+	if f.fh == nil {
+		if err := f.setFh(f.flag); err != nil {
+			return false, err
+		}
+
+		defer f.ensureFhState()
+	}
+
+	if processRecordLocks {
+		ok, err := f.reserveRange(r, wait)
+		if err != nil || !ok {
+			return false, err
+		}
+
+		// Drops the reservation if locking fails.
+		defer f.syncRecords()
+	}
+
+	ok, err := f.lockRegion(r, wait)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	f.ranges = setRange(f.ranges, r, mergeRanges)
+
+	f.startHeartbeat()
+
+	return true, nil
+}
+
+// covered reports whether r is entirely held in ranges with the same mode, or with an exclusive lock.
+func covered(ranges []Range, r Range) bool {
+	next := r.Offset
+
+	for _, h := range ranges {
+		if h.end() <= next {
+			continue
+		}
+
+		if h.Offset > next || (r.Exclusive && !h.Exclusive) {
+			return false
+		}
+
+		next = h.end()
+		if next >= r.end() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overlaps reports whether any of ranges shares at least one byte with r.
+func overlaps(ranges []Range, r Range) bool {
+	for _, h := range ranges {
+		if h.Offset < r.end() && r.Offset < h.end() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clearRange removes r from the sorted, non-overlapping ranges,
+// splitting the held ranges that extend past either end of r.
+func clearRange(ranges []Range, r Range) []Range {
+	var out []Range
+
+	for _, h := range ranges {
+		if h.end() <= r.Offset || h.Offset >= r.end() {
+			out = append(out, h)
+			continue
+		}
+
+		if h.Offset < r.Offset {
+			out = append(out, newRange(h.Offset, r.Offset, h.Exclusive))
+		}
+
+		if h.end() > r.end() {
+			out = append(out, newRange(r.end(), h.end(), h.Exclusive))
+		}
+	}
+
+	return out
+}
+
+// setRange adds r to the sorted, non-overlapping ranges,
+// replacing the mode of the overlapping parts.
+// If merge is true, adjacent ranges with the same mode are merged.
+func setRange(ranges []Range, r Range, merge bool) []Range {
+	out := clearRange(ranges, r)
+
+	i, _ := slices.BinarySearchFunc(out, r.Offset, func(h Range, offset int64) int {
+		return cmp.Compare(h.Offset, offset)
+	})
+
+	out = slices.Insert(out, i, r)
+
+	if !merge {
+		return out
+	}
+
+	merged := out[:1]
+
+	for _, h := range out[1:] {
+		last := &merged[len(merged)-1]
+
+		if last.Exclusive == h.Exclusive && last.end() == h.Offset {
+			*last = newRange(last.Offset, h.end(), h.Exclusive)
+			continue
+		}
+
+		merged = append(merged, h)
+	}
+
+	return merged
+}
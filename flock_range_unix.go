@@ -0,0 +1,113 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// The kernel splits and merges POSIX record locks itself.
+const mergeRanges = true
+
+// Record locks belong to the process, except open file description locks on Linux.
+const processRecordLocks = !ofdAvailable
+
+// errRecordLockMixed is returned when mixing whole-file and byte-range locks
+// while the whole-file lock is a record lock too (see recordWholeFile).
+var errRecordLockMixed = errors.New("cannot mix whole-file and byte-range record locks")
+
+// lockRegion takes a POSIX record lock on r.
+// It returns false without error if wait is false and the range is locked by another process.
+func (f *Flock) lockRegion(r Range, wait bool) (bool, error) {
+	if f.recordWholeFile() && (f.l || f.r || (processRecordLocks && f.wholeFileHeld())) {
+		return false, &fs.PathError{Op: "LockRange", Path: f.path, Err: errRecordLockMixed}
+	}
+
+	cmd := f.setlkCmd(wait)
+
+	lt := int16(unix.F_RDLCK)
+	if r.Exclusive {
+		lt = unix.F_WRLCK
+	}
+
+	err := setlkRange(f.fh.Fd(), cmd, lt, r)
+
+	// An exclusive record lock needs a descriptor opened for writing.
+	if errors.Is(err, unix.EBADF) && r.Exclusive && !f.held() {
+		f.resetFh()
+
+		if err = f.setFh(f.flag | os.O_RDWR); err != nil {
+			return false, err
+		}
+
+		err = setlkRange(f.fh.Fd(), cmd, lt, r)
+	}
+
+	switch {
+	case err == nil:
+		return true, nil
+	case !wait && (errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EACCES)):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// unlockRegion releases the POSIX record locks on r,
+// except the parts held by other *Flock values of the process when the locks belong to the process.
+func (f *Flock) unlockRegion(r Range) error {
+	for _, free := range f.freeRanges(r) {
+		if err := setlkRange(f.fh.Fd(), f.setlkCmd(false), unix.F_UNLCK, free); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wholeFileHeld reports whether a *Flock of the process holds a whole-file lock on the file.
+func (f *Flock) wholeFileHeld() bool {
+	id, err := fileIDOf(f.fh)
+
+	return err == nil && registryMode(id) != Unlocked
+}
+
+// setlkCmd returns the fcntl command used to set a record lock:
+// open file description locks are used on Linux.
+func (f *Flock) setlkCmd(wait bool) int {
+	switch {
+	case ofdAvailable && wait:
+		return fOFDSetlkw
+	case ofdAvailable:
+		return fOFDSetlk
+	case wait:
+		return unix.F_SETLKW
+	default:
+		return unix.F_SETLK
+	}
+}
+
+// setlkRange calls FcntlFlock with cmd for the bytes of r.
+func setlkRange(fd uintptr, cmd int, lt int16, r Range) error {
+	for {
+		err := unix.FcntlFlock(fd, cmd, &unix.Flock_t{
+			Type:   lt,
+			Whence: io.SeekStart,
+			Start:  r.Offset,
+			Len:    r.Length,
+		})
+		if !errors.Is(err, unix.EINTR) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+
+	"golang.org/x/sys/windows"
+)
+
+// UnlockFileEx only accepts the exact ranges that were passed to LockFileEx,
+// so each locked range is tracked separately.
+const mergeRanges = false
+
+// LockFileEx locks belong to the file handle.
+const processRecordLocks = false
+
+// errRangeConversion is returned when a range lock would need to be converted,
+// which LockFileEx does not support.
+var errRangeConversion = errors.New("cannot convert a locked range on Windows")
+
+// lockRegion takes a LockFileEx lock on r.
+// It returns false without error if wait is false and the range is locked by another process.
+func (f *Flock) lockRegion(r Range, wait bool) (bool, error) {
+	if overlaps(f.ranges, r) {
+		return false, &fs.PathError{Op: "LockRange", Path: f.path, Err: errRangeConversion}
+	}
+
+	flag := uint32(winLockfileSharedLock)
+	if r.Exclusive {
+		flag = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	if !wait {
+		flag |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+
+	err := f.lockFileRange(flag, r)
+	if err != nil {
+		if !wait && (errors.Is(err, ErrorLockViolation) || errors.Is(err, windows.ERROR_IO_PENDING)) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// unlockRegion releases the locks on r.
+// A held range that extends past either end of r is unlocked entirely,
+// then its remaining parts are locked again.
+// This is not atomic: another process may take the remaining parts in between,
+// in which case they are dropped from the tracked ranges and an error is returned.
+func (f *Flock) unlockRegion(r Range) error {
+	var lost []Range
+
+	for _, h := range f.ranges {
+		if !overlaps([]Range{h}, r) {
+			continue
+		}
+
+		if err := f.unlockFileRange(h); err != nil {
+			return err
+		}
+
+		for _, rest := range clearRange([]Range{h}, r) {
+			flag := uint32(winLockfileSharedLock | windows.LOCKFILE_FAIL_IMMEDIATELY)
+			if rest.Exclusive {
+				flag |= windows.LOCKFILE_EXCLUSIVE_LOCK
+			}
+
+			if err := f.lockFileRange(flag, rest); err != nil {
+				lost = append(lost, rest)
+			}
+		}
+	}
+
+	if len(lost) == 0 {
+		return nil
+	}
+
+	f.ranges = clearRange(f.ranges, r)
+
+	for _, rest := range lost {
+		f.ranges = clearRange(f.ranges, rest)
+	}
+
+	return &fs.PathError{Op: "UnlockRange", Path: f.path, Err: ErrorLockViolation}
+}
+
+func (f *Flock) lockFileRange(flag uint32, r Range) error {
+	low, high := splitUint64(uint64(r.Offset))
+	lenLow, lenHigh := splitUint64(rangeLength(r))
+
+	ol := &windows.Overlapped{Offset: low, OffsetHigh: high}
+
+	err := windows.LockFileEx(windows.Handle(f.fh.Fd()), flag, 0, lenLow, lenHigh, ol)
+	if err != nil && !errors.Is(err, windows.Errno(0)) {
+		return err
+	}
+
+	return nil
+}
+
+func (f *Flock) unlockFileRange(r Range) error {
+	low, high := splitUint64(uint64(r.Offset))
+	lenLow, lenHigh := splitUint64(rangeLength(r))
+
+	ol := &windows.Overlapped{Offset: low, OffsetHigh: high}
+
+	err := windows.UnlockFileEx(windows.Handle(f.fh.Fd()), 0, lenLow, lenHigh, ol)
+	if err != nil && !errors.Is(err, windows.Errno(0)) {
+		return err
+	}
+
+	return nil
+}
+
+// rangeLength returns the number of bytes to pass to LockFileEx for r,
+// using the largest possible length for ranges that extend to the end of the file.
+func rangeLength(r Range) uint64 {
+	if r.Length == 0 {
+		return math.MaxUint64 - uint64(r.Offset)
+	}
+
+	return uint64(r.Length)
+}
+
+func splitUint64(v uint64) (low, high uint32) {
+	return uint32(v), uint32(v >> 32)
+}
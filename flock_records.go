@@ -0,0 +1,212 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"os"
+	"slices"
+	"sync"
+)
+
+// The record table tracks the byte-range locks held by the *Flock values of the process, by file,
+// where they are POSIX record locks owned by the process rather than by a file descriptor (see processRecordLocks).
+// The OS merges the ranges locked through every descriptor of the process, never reports conflicts between them,
+// and releases them all when any descriptor of the file is closed:
+// the table detects the conflicts between the *Flock values of the process,
+// lets the OS release only the bytes that no other *Flock holds,
+// and keeps the descriptors of a file open while ranges are held on it.
+var (
+	recordsMu sync.Mutex
+	records   = map[fileID]*fileRecords{}
+)
+
+// fileRecords are the byte-range locks held on a file by the *Flock values of the process.
+type fileRecords struct {
+	held map[*Flock][]Range
+	// parked are the descriptors closed while ranges were held on the file:
+	// they are closed once the last range is released.
+	parked []*os.File
+	// changed is closed when the ranges change, to wake up the *Flock values waiting for a conflicting range.
+	changed chan struct{}
+}
+
+// reserveRange records r as held by f before it is locked by the OS,
+// so that the other *Flock values of the process see it as held.
+// If another *Flock of the process holds a conflicting range,
+// it waits until the range is released if wait is true, and returns false otherwise.
+// The caller must hold the RW-mutex lock, and call syncRecords once f.ranges is up to date.
+func (f *Flock) reserveRange(r Range, wait bool) (bool, error) {
+	id := f.recordID
+
+	if !f.recorded {
+		var err error
+
+		id, err = fileIDOf(f.fh)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+
+	for {
+		fr := records[id]
+		if fr == nil || !fr.conflicts(f, r) {
+			break
+		}
+
+		if !wait {
+			return false, nil
+		}
+
+		if fr.changed == nil {
+			fr.changed = make(chan struct{})
+		}
+
+		changed := fr.changed
+
+		recordsMu.Unlock()
+		<-changed
+		recordsMu.Lock()
+	}
+
+	f.setRecords(id, setRange(f.ranges, r, mergeRanges))
+
+	return true, nil
+}
+
+// syncRecords records the ranges held by f in the table.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) syncRecords() {
+	if !f.recorded {
+		return
+	}
+
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+
+	f.setRecords(f.recordID, f.ranges)
+}
+
+// setRecords records ranges as the ranges held by f on the file id,
+// and closes the parked descriptors of the file once no range is held on it.
+// The caller must hold recordsMu.
+func (f *Flock) setRecords(id fileID, ranges []Range) {
+	fr := records[id]
+	if fr == nil {
+		fr = &fileRecords{held: map[*Flock][]Range{}}
+		records[id] = fr
+	}
+
+	if len(ranges) == 0 {
+		delete(fr.held, f)
+
+		f.recorded = false
+	} else {
+		fr.held[f] = slices.Clone(ranges)
+
+		f.recordID, f.recorded = id, true
+	}
+
+	if fr.changed != nil {
+		close(fr.changed)
+		fr.changed = nil
+	}
+
+	if len(fr.held) > 0 {
+		return
+	}
+
+	for _, fh := range fr.parked {
+		_ = fh.Close()
+	}
+
+	delete(records, id)
+}
+
+// freeRanges returns the parts of r that no other *Flock of the process holds,
+// which are the only ones the OS must release.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) freeRanges(r Range) []Range {
+	free := []Range{r}
+
+	if !f.recorded {
+		return free
+	}
+
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+
+	for g, ranges := range records[f.recordID].held {
+		if g == f {
+			continue
+		}
+
+		for _, h := range ranges {
+			free = clearRange(free, h)
+		}
+	}
+
+	return free
+}
+
+// rangesHeld reports whether another *Flock of the process holds ranges on the file id.
+func (f *Flock) rangesHeld(id fileID) bool {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+
+	fr := records[id]
+	if fr == nil {
+		return false
+	}
+
+	for g := range fr.held {
+		if g != f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parkFile keeps fh open if the process holds ranges on its file, as closing it would release them.
+// It reports whether fh was parked, in which case it is closed with the last range.
+func parkFile(fh *os.File) bool {
+	id, err := fileIDOf(fh)
+	if err != nil {
+		return false
+	}
+
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+
+	fr := records[id]
+	if fr == nil {
+		return false
+	}
+
+	fr.parked = append(fr.parked, fh)
+
+	return true
+}
+
+// conflicts reports whether a *Flock other than f holds a range that conflicts with r.
+func (fr *fileRecords) conflicts(f *Flock, r Range) bool {
+	for g, ranges := range fr.held {
+		if g == f {
+			continue
+		}
+
+		for _, h := range ranges {
+			if (r.Exclusive || h.Exclusive) && overlaps([]Range{h}, r) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,212 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"io/fs"
+	"slices"
+)
+
+// Depth returns the number of outstanding holds.
+// Without the Reentrant option, it is 1 while a whole-file lock is held and 0 otherwise.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func (f *Flock) Depth() int {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	switch {
+	case f.reentrant:
+		return len(f.holds)
+	case f.l || f.r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// reenter reports whether the lock tracked by locked is already held,
+// in which case a reentrant *Flock counts one more hold.
+// The exclusive lock also satisfies a reentrant shared lock request.
+func (f *Flock) reenter(locked *bool) bool {
+	exclusive := locked == &f.l
+
+	if !*locked && (exclusive || !f.reentrant || !f.l) {
+		return false
+	}
+
+	if f.reentrant {
+		f.holds = append(f.holds, exclusive)
+	}
+
+	return true
+}
+
+// acquired marks the lock tracked by locked as held, counting the hold in reentrant mode.
+func (f *Flock) acquired(locked *bool) {
+	*locked = true
+
+	if f.reentrant {
+		f.holds = append(f.holds, locked == &f.l)
+	}
+
+	f.register()
+	f.writeOwner()
+	f.startHeartbeat()
+}
+
+// unhold releases the most recent hold of a reentrant *Flock.
+// It returns true when Unlock must return without releasing the lock,
+// either because holds remain or because there is no hold to release.
+func (f *Flock) unhold() (bool, error) {
+	if !f.reentrant || (len(f.holds) == 0 && f.held()) {
+		return false, nil
+	}
+
+	if len(f.holds) == 0 {
+		return true, &fs.PathError{Op: "Unlock", Path: f.path, Err: ErrNotLocked}
+	}
+
+	f.holds = f.holds[:len(f.holds)-1]
+
+	if len(f.holds) == 0 {
+		return false, nil
+	}
+
+	return true, f.settle()
+}
+
+// settle downgrades the lock of a reentrant *Flock when only shared holds remain.
+func (f *Flock) settle() error {
+	if !f.l || slices.Contains(f.holds, true) {
+		return nil
+	}
+
+	f.clearOwner()
+
+	ok, _, err := f.convertLock(false, true)
+	if ok {
+		f.l, f.r = false, true
+	}
+
+	if !f.l && !f.r {
+		// The conversion failed and the lock was lost.
+		f.holds = nil
+	}
+
+	f.register()
+	f.ensureFhState()
+
+	return err
+}
+
+// release releases one hold in the given mode,
+// keeping the lock held by the OS for the remaining holders.
+// It reports false if no hold in that mode exists.
+func (f *Flock) release(exclusive bool) (bool, error) {
+	if f.reentrant {
+		i := lastHold(f.holds, exclusive)
+		if i < 0 {
+			return false, nil
+		}
+
+		f.holds = slices.Delete(f.holds, i, i+1)
+
+		if len(f.holds) == 0 {
+			return true, f.unlockWhole()
+		}
+
+		return true, f.settle()
+	}
+
+	mode := Shared
+	if exclusive {
+		mode = Exclusive
+	}
+
+	switch {
+	case exclusive && !f.l, !exclusive && !f.l && !f.r:
+		return false, nil
+
+	case f.handles[mode] > 1:
+		// Other handles share the lock in that mode.
+		f.handles[mode]--
+
+		return true, nil
+
+	case exclusive && f.r:
+		// Someone else holds the shared lock.
+		f.handles[mode] = 0
+
+		f.clearOwner()
+
+		ok, _, err := f.convertLock(false, true)
+		if ok {
+			f.l = false
+		}
+
+		f.register()
+		f.ensureFhState()
+
+		return true, err
+
+	case !exclusive && f.l:
+		// Someone else holds the exclusive lock.
+		f.handles[mode] = 0
+		f.r = false
+
+		return true, nil
+
+	default:
+		return true, f.unlockWhole()
+	}
+}
+
+// unlockWhole releases the whole-file lock, keeping the byte-range locks and the file descriptor if any.
+func (f *Flock) unlockWhole() error {
+	if len(f.ranges) == 0 {
+		return f.unlock()
+	}
+
+	f.clearOwner()
+
+	if err := f.unlockFile(); err != nil {
+		return err
+	}
+
+	f.l, f.r = false, false
+	f.holds = nil
+	f.handles = [Exclusive + 1]int{}
+	f.gen++
+
+	f.register()
+
+	return nil
+}
+
+// convertedHold returns the index of the hold converted by Upgrade or Downgrade in reentrant mode,
+// that is the most recent hold for Upgrade and the most recent exclusive hold for Downgrade, or -1.
+func (f *Flock) convertedHold(exclusive bool) int {
+	switch {
+	case !f.reentrant:
+		return -1
+	case exclusive:
+		return len(f.holds) - 1
+	default:
+		return lastHold(f.holds, true)
+	}
+}
+
+// lastHold returns the index of the most recent hold in the given mode, or -1.
+func lastHold(holds []bool, exclusive bool) int {
+	for i := len(holds) - 1; i >= 0; i-- {
+		if holds[i] == exclusive {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import "sync"
+
+// The registry records the whole-file locks held by the *Flock values of the process, by file,
+// so that the *Flock values referring to the same file through different descriptors or paths know about each other.
+var (
+	registryMu sync.Mutex
+	registry   = map[fileID]map[*Flock]Mode{}
+)
+
+// ProcessMode returns the strongest whole-file lock held on the file by the *Flock values of the process,
+// including this one.
+// Files are matched by device and inode (volume and file index on Windows),
+// so different paths to the same file report the same mode.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func (f *Flock) ProcessMode() Mode {
+	mode, _ := f.processHolding()
+	return mode
+}
+
+// ProcessHolders returns the number of *Flock values of the process holding a whole-file lock on the file,
+// including this one.
+// On AIX and Solaris, where only one *Flock of the process can hold a lock on a file at a time
+// (POSIX record locks belong to the process), it is at most 1.
+//
+// Warning: by the time you use the returned value, the state may have changed.
+func (f *Flock) ProcessHolders() int {
+	_, n := f.processHolding()
+	return n
+}
+
+func (f *Flock) processHolding() (Mode, int) {
+	f.m.RLock()
+	id, err := f.fileID()
+	f.m.RUnlock()
+
+	if err != nil {
+		return Unlocked, 0
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return registryModeLocked(id), len(registry[id])
+}
+
+// registryMode returns the strongest whole-file lock held on the file by the process.
+func registryMode(id fileID) Mode {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return registryModeLocked(id)
+}
+
+// registryModeLocked is like registryMode, but the caller must hold registryMu.
+func registryModeLocked(id fileID) Mode {
+	mode := Unlocked
+
+	for _, m := range registry[id] {
+		mode = max(mode, m)
+	}
+
+	return mode
+}
+
+// fileID returns the identity of the file, through the file descriptor if it is open.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) fileID() (fileID, error) {
+	if f.registered {
+		return f.id, nil
+	}
+
+	if f.fh != nil {
+		return fileIDOf(f.fh)
+	}
+
+	return pathFileID(f.path)
+}
+
+// mode returns the whole-file lock held by the *Flock.
+func (f *Flock) mode() Mode {
+	switch {
+	case f.l:
+		return Exclusive
+	case f.r:
+		return Shared
+	default:
+		return Unlocked
+	}
+}
+
+// register records the whole-file lock held by the *Flock in the registry,
+// and must be called each time it changes.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) register() {
+	mode := f.mode()
+
+	if mode != Unlocked && !f.registered {
+		id, err := fileIDOf(f.fh)
+		if err != nil {
+			// The lock cannot be matched with the other *Flock values.
+			return
+		}
+
+		f.id, f.registered = id, true
+	}
+
+	if !f.registered {
+		return
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	holders := registry[f.id]
+
+	if mode == Unlocked {
+		delete(holders, f)
+
+		if len(holders) == 0 {
+			delete(registry, f.id)
+		}
+
+		f.registered = false
+
+		return
+	}
+
+	if holders == nil {
+		holders = map[*Flock]Mode{}
+		registry[f.id] = holders
+	}
+
+	holders[f] = mode
+}
+
+// contended reports whether another *Flock of the process holds a whole-file lock
+// that conflicts with a lock in the given mode.
+// Such conflicts are detected without asking the OS,
+// which does not report them for POSIX locks.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) contended(exclusive bool) bool {
+	id, err := f.fileID()
+	if err != nil {
+		return false
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for g, m := range registry[id] {
+		if g != f && (exclusive || m == Exclusive) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns the delay before the next attempt,
+// given the number of attempts made so far (starting at 1) and the previous delay (zero before the first retry).
+// It returns false to stop retrying.
+type Backoff func(attempt int, prev time.Duration) (time.Duration, bool)
+
+// RetryPolicy controls how TryLockContextPolicy and TryRLockContextPolicy retry.
+type RetryPolicy struct {
+	// Backoff computes the delay between attempts.
+	// A nil Backoff retries immediately.
+	Backoff Backoff
+
+	// RetryOn lists the errors that are retried instead of returned,
+	// such as syscall.EINTR, syscall.ENOLCK, or syscall.EIO.
+	// They are matched with errors.Is.
+	RetryOn []error
+}
+
+// ConstantBackoff waits the same delay between all attempts.
+func ConstantBackoff(delay time.Duration) Backoff {
+	return func(_ int, _ time.Duration) (time.Duration, bool) {
+		return delay, true
+	}
+}
+
+// ExponentialBackoff doubles the delay after each attempt, starting at base and capped at maxDelay.
+func ExponentialBackoff(base, maxDelay time.Duration) Backoff {
+	return func(attempt int, _ time.Duration) (time.Duration, bool) {
+		delay := base
+
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+
+		return min(delay, maxDelay), true
+	}
+}
+
+// DecorrelatedJitterBackoff picks a random delay between base and three times the previous delay, capped at maxDelay.
+// The randomness spreads waiters that started together, which avoids thundering herds.
+func DecorrelatedJitterBackoff(base, maxDelay time.Duration) Backoff {
+	return func(_ int, prev time.Duration) (time.Duration, bool) {
+		upper := max(prev, base) * 3
+		if upper <= base {
+			return min(base, maxDelay), true
+		}
+
+		//nolint:gosec // The jitter does not need a cryptographically secure random number.
+		delay := base + rand.N(upper-base)
+
+		return min(delay, maxDelay), true
+	}
+}
+
+// WithMaxAttempts stops retrying after n attempts in total.
+func (b Backoff) WithMaxAttempts(n int) Backoff {
+	return func(attempt int, prev time.Duration) (time.Duration, bool) {
+		if attempt >= n {
+			return 0, false
+		}
+
+		return b.next(attempt, prev)
+	}
+}
+
+// WithMaxDelay caps the delay between attempts to maxDelay.
+func (b Backoff) WithMaxDelay(maxDelay time.Duration) Backoff {
+	return func(attempt int, prev time.Duration) (time.Duration, bool) {
+		delay, ok := b.next(attempt, prev)
+
+		return min(delay, maxDelay), ok
+	}
+}
+
+func (b Backoff) next(attempt int, prev time.Duration) (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+
+	return b(attempt, prev)
+}
+
+// TryLockContextPolicy repeatedly tries to take an exclusive lock until one of the conditions is met:
+// - TryLock succeeds
+// - TryLock fails with an error that is not listed in policy.RetryOn
+// - policy.Backoff stops retrying
+// - Context Done channel is closed.
+//
+// When the policy stops retrying, the function returns false and the error of the last attempt, if any.
+func (f *Flock) TryLockContextPolicy(ctx context.Context, policy RetryPolicy) (bool, error) {
+	return f.tryPolicy(ctx, f.TryLock, policy)
+}
+
+// TryRLockContextPolicy repeatedly tries to take a shared lock until one of the conditions is met:
+// - TryRLock succeeds
+// - TryRLock fails with an error that is not listed in policy.RetryOn
+// - policy.Backoff stops retrying
+// - Context Done channel is closed.
+//
+// When the policy stops retrying, the function returns false and the error of the last attempt, if any.
+func (f *Flock) TryRLockContextPolicy(ctx context.Context, policy RetryPolicy) (bool, error) {
+	return f.tryPolicy(ctx, f.TryRLock, policy)
+}
+
+// tryPolicy waits for the turn of the *Flock among fair waiters, then retries fn according to policy.
+func (f *Flock) tryPolicy(ctx context.Context, fn func() (bool, error), policy RetryPolicy) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	leave, err := f.waitTurn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer leave()
+
+	return retry(ctx, fn, policy)
+}
+
+// retry calls fn until it succeeds, fails with an error that is not retried, or the policy stops retrying.
+func retry(ctx context.Context, fn func() (bool, error), policy RetryPolicy) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	var delay time.Duration
+
+	for attempt := 1; ; attempt++ {
+		ok, err := fn()
+		if ok || (err != nil && !policy.retryable(err)) {
+			return ok, err
+		}
+
+		next, retry := policy.Backoff.next(attempt, delay)
+		if !retry {
+			return false, err
+		}
+
+		delay = next
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	for _, target := range p.RetryOn {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"runtime"
+	"time"
+)
+
+// startTimeSlack is the difference allowed between the start time of a process and the one recorded in its owner record.
+// On Linux, the start time is only accurate to the second, and follows the adjustments of the clock.
+const startTimeSlack = 2 * time.Second
+
+// testHookBreak is called by Break between the staleness check and the lock of the file.
+var testHookBreak = func() {}
+
+// IsStale reports whether the lock file was left behind by a holder that is gone,
+// for lock-file conventions where the existence of the file is the lock.
+//
+// If the file contains an owner record (see RecordOwner) written on the current host,
+// the file is stale if the owner process is no longer running,
+// or if its PID was reused by a process started at another time than the one recorded.
+// Otherwise, or if the start time of a running process cannot be read (only Linux, macOS, and Windows report it),
+// the liveness of the owner cannot be checked,
+// and the file is stale if it was not modified for more than maxAge (see Stat()).
+// A zero or negative maxAge disables this check.
+//
+// The file is never stale while the *Flock holds a lock, or if it does not exist.
+func (f *Flock) IsStale(maxAge time.Duration) (bool, error) {
+	f.m.RLock()
+	held := f.held()
+	f.m.RUnlock()
+
+	if held {
+		return false, nil
+	}
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	owner, err := ReadOwner(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return staleFile(fi, owner, err, maxAge)
+}
+
+// Break removes the lock file if it is stale (see IsStale()),
+// and reports whether it was removed by this call.
+//
+// The file is locked exclusively before it is checked again and removed,
+// so that exactly one of the contenders breaking the same stale lock file wins:
+// the others fail to take the lock, or find the file unlinked, or replaced by a new lock file that is not stale.
+// A file locked by a live holder is never removed.
+func (f *Flock) Break(maxAge time.Duration) (bool, error) {
+	stale, err := f.IsStale(maxAge)
+	if err != nil || !stale {
+		return false, err
+	}
+
+	testHookBreak()
+
+	// The lock only serves to break the file: the options acting on the lock file are left out.
+	g := f.clone()
+	g.flag &^= os.O_CREATE
+	g.recordOwner = false
+	g.heartbeat = 0
+	g.removeOnUnlock = false
+	g.createParents = false
+	g.reentrant = false
+	g.fair = false
+
+	locked, err := g.TryLock()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Another contender broke it first.
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if !locked {
+		return false, nil
+	}
+
+	defer func() { _ = g.Unlock() }()
+
+	// The file at the path when the lock was taken may have been replaced by a new lock file.
+	fh := g.File()
+
+	fi, err := fh.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	owner, err := parseOwner(g.path, fh)
+
+	stale, err = staleFile(fi, owner, err, maxAge)
+	if err != nil || !stale {
+		return false, err
+	}
+
+	if runtime.GOOS == "windows" {
+		// An open file cannot be removed on Windows:
+		// removing it once unlocked fails if another process has opened it in the meantime.
+		if err := g.Unlock(); err != nil {
+			return false, err
+		}
+	}
+
+	if err := os.Remove(g.path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// staleFile reports whether the lock file described by fi is stale,
+// given its owner record, or the error of reading it.
+func staleFile(fi fs.FileInfo, owner *Owner, ownerErr error, maxAge time.Duration) (bool, error) {
+	switch {
+	case ownerErr == nil:
+		if host, _ := os.Hostname(); owner.Hostname != host {
+			break
+		}
+
+		if !processAlive(owner.PID) {
+			return true, nil
+		}
+
+		// The PID may have been reused by another process.
+		if start, err := processStartTime(owner.PID); err == nil && !owner.Started.IsZero() {
+			d := start.Sub(owner.Started)
+
+			return d < -startTimeSlack || d > startTimeSlack, nil
+		}
+
+	case !errors.Is(ownerErr, ErrNoOwner):
+		return false, ownerErr
+	}
+
+	return maxAge > 0 && time.Since(fi.ModTime()) > maxAge, nil
+}
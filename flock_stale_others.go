@@ -0,0 +1,13 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build (!unix && !windows) || plan9
+
+package flock
+
+// processAlive assumes that the process is running, as it cannot be checked.
+func processAlive(int) bool {
+	return true
+}
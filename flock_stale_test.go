@@ -0,0 +1,191 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlock_IsStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+
+	f := flock.New(path)
+
+	stale, err := f.IsStale(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	err = os.WriteFile(path, nil, 0o600)
+	require.NoError(t, err)
+
+	stale, err = f.IsStale(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	err = os.Chtimes(path, old, old)
+	require.NoError(t, err)
+
+	stale, err = f.IsStale(time.Hour)
+	require.NoError(t, err)
+	assert.True(t, stale)
+
+	stale, err = f.IsStale(0)
+	require.NoError(t, err)
+	assert.False(t, stale)
+}
+
+func TestFlock_IsStale_owner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+
+	holder := flock.New(path, flock.RecordOwner(""))
+
+	err := holder.Lock()
+	require.NoError(t, err)
+
+	// the owner is alive.
+	stale, err := flock.New(path).IsStale(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	switch runtime.GOOS {
+	case "darwin", "linux", "windows":
+		// whatever the age of the file, as its start time matches.
+		stale, err = flock.New(path).IsStale(time.Nanosecond)
+		require.NoError(t, err)
+		assert.False(t, stale)
+	}
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	// a record left by a process that is gone.
+	record := fmt.Sprintf("\n{\"version\":1,\"pid\":%d,\"hostname\":%q}", 0x7ffffff0, host)
+
+	err = os.WriteFile(path, []byte(record), 0o600)
+	require.NoError(t, err)
+
+	stale, err = flock.New(path).IsStale(0)
+	require.NoError(t, err)
+	assert.True(t, stale)
+
+	// a record left by a process whose PID was reused.
+	record = fmt.Sprintf("\n{\"version\":1,\"pid\":%d,\"hostname\":%q,\"started\":\"2000-01-01T00:00:00Z\"}", os.Getpid(), host)
+
+	err = os.WriteFile(path, []byte(record), 0o600)
+	require.NoError(t, err)
+
+	switch runtime.GOOS {
+	case "darwin", "linux", "windows":
+		stale, err = flock.New(path).IsStale(0)
+		require.NoError(t, err)
+		assert.True(t, stale)
+	default:
+		// the start time cannot be checked: the age of the file is.
+		stale, err = flock.New(path).IsStale(time.Hour)
+		require.NoError(t, err)
+		assert.False(t, stale)
+
+		old := time.Now().Add(-2 * time.Hour)
+
+		err = os.Chtimes(path, old, old)
+		require.NoError(t, err)
+
+		stale, err = flock.New(path).IsStale(time.Hour)
+		require.NoError(t, err)
+		assert.True(t, stale)
+	}
+}
+
+func TestFlock_Break(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+
+	f := flock.New(path)
+
+	err := os.WriteFile(path, nil, 0o600)
+	require.NoError(t, err)
+
+	broken, err := f.Break(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, broken)
+	assert.FileExists(t, path)
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	err = os.Chtimes(path, old, old)
+	require.NoError(t, err)
+
+	broken, err = f.Break(time.Hour)
+	require.NoError(t, err)
+	assert.True(t, broken)
+	assert.NoFileExists(t, path)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	broken, err = f.Break(time.Hour)
+	require.NoError(t, err)
+	assert.False(t, broken)
+}
+
+func TestFlock_Break_concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.lock")
+
+	err := os.WriteFile(path, nil, 0o600)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	err = os.Chtimes(path, old, old)
+	require.NoError(t, err)
+
+	results := make(chan bool, 8)
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			broken, err := flock.New(path).Break(time.Hour)
+			assert.NoError(t, err)
+
+			results <- broken
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+
+	var winners int
+
+	for broken := range results {
+		if broken {
+			winners++
+		}
+	}
+
+	assert.Equal(t, 1, winners)
+}
@@ -0,0 +1,24 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package flock
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// processAlive reports whether the process is running, assuming it is when it cannot be checked.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// The signal 0 only checks that the process exists, and EPERM means it belongs to another user.
+	return !errors.Is(unix.Kill(pid, 0), unix.ESRCH)
+}
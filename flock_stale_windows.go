@@ -0,0 +1,40 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package flock
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code of a running process (STILL_ACTIVE).
+const stillActive = 259
+
+// processAlive reports whether the process is running, assuming it is when it cannot be checked.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// OpenProcess fails with ERROR_INVALID_PARAMETER when there is no such process.
+		return !errors.Is(err, windows.ERROR_INVALID_PARAMETER)
+	}
+
+	defer func() { _ = windows.CloseHandle(h) }()
+
+	var code uint32
+
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+
+	return code == stillActive
+}
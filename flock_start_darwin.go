@@ -0,0 +1,29 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build darwin
+
+package flock
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// processStartTime returns the start time of the process reported by the kern.proc.pid sysctl.
+func processStartTime(pid int) (time.Time, error) {
+	kp, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The sysctl returns an empty record when there is no such process.
+	if int(kp.Proc.P_pid) != pid {
+		return time.Time{}, unix.ESRCH
+	}
+
+	return time.Unix(kp.Proc.P_starttime.Unix()), nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package flock
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the unit of the start time in /proc/<pid>/stat (USER_HZ),
+// which is 100 on every architecture.
+const clockTicks = 100
+
+var errProcStat = errors.New("malformed /proc stat file")
+
+// processStartTime returns the start time of the process,
+// computed from its start time in clock ticks since boot, and the boot time, both reported by /proc.
+// It is accurate to the second, as the boot time is.
+func processStartTime(pid int) (time.Time, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The command name in the second field may contain spaces and parentheses.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return time.Time{}, errProcStat
+	}
+
+	// The start time is the 22nd field, and the fields after the command name start with the 3rd one.
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, errProcStat
+	}
+
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, errProcStat
+	}
+
+	boot, err := bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+// bootTime returns the boot time reported by /proc/stat.
+func bootTime() (time.Time, error) {
+	fh, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer func() { _ = fh.Close() }()
+
+	scanner := bufio.NewScanner(fh)
+
+	for scanner.Scan() {
+		v, ok := strings.CutPrefix(scanner.Text(), "btime ")
+		if !ok {
+			continue
+		}
+
+		sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return time.Time{}, errProcStat
+		}
+
+		return time.Unix(sec, 0), nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, errProcStat
+}
@@ -0,0 +1,17 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !linux && !windows
+
+package flock
+
+import (
+	"errors"
+	"time"
+)
+
+func processStartTime(int) (time.Time, error) {
+	return time.Time{}, errors.ErrUnsupported
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package flock
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// processStartTime returns the creation time of the process.
+func processStartTime(pid int) (time.Time, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer func() { _ = windows.CloseHandle(h) }()
+
+	var creation, exit, kernel, user windows.Filetime
+
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, creation.Nanoseconds()), nil
+}
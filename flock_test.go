@@ -9,8 +9,10 @@ package flock_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -40,6 +42,14 @@ func Test_dir(t *testing.T) {
 	suite.Run(t, &TestSuite{dir: true, opts: []flock.Option{flock.SetFlag(os.O_RDONLY)}})
 }
 
+func Test_ofd(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("open file description locks are only supported on Linux")
+	}
+
+	suite.Run(t, &TestSuite{opts: []flock.Option{flock.UseOFDLocks(), flock.SetFlag(os.O_CREATE | os.O_RDWR)}})
+}
+
 func (s *TestSuite) SetupTest() {
 	if s.dir {
 		s.path = s.T().TempDir()
@@ -208,6 +218,604 @@ func (s *TestSuite) TestFlock_TryRLockContext() {
 	s.False(locked)
 }
 
+func (s *TestSuite) TestFlock_TryLockContextPolicy() {
+	policy := flock.RetryPolicy{
+		Backoff: flock.ExponentialBackoff(time.Millisecond, 10*time.Millisecond).WithMaxAttempts(3),
+	}
+
+	// happy path
+	locked, err := s.flock.TryLockContextPolicy(context.Background(), policy)
+	s.Require().NoError(err)
+	s.True(locked)
+
+	// attempts exhausted
+	locked, err = flock.New(s.path, s.opts...).TryRLockContextPolicy(context.Background(), policy)
+	s.Require().NoError(err)
+	s.False(locked)
+
+	// timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	locked, err = flock.New(s.path, s.opts...).TryLockContextPolicy(ctx, flock.RetryPolicy{Backoff: flock.ConstantBackoff(time.Second)})
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+	s.False(locked)
+}
+
+func (s *TestSuite) TestFlock_ProcessMode() {
+	switch runtime.GOOS {
+	case "aix", "solaris", "illumos":
+		s.T().Skip("a second shared lock cannot be taken in the same process with POSIX locks")
+	}
+
+	s.Equal(flock.Unlocked, s.flock.ProcessMode())
+	s.Equal(0, s.flock.ProcessHolders())
+
+	err := s.flock.RLock()
+	s.Require().NoError(err)
+
+	gf := flock.New(s.path, s.opts...)
+
+	locked, err := gf.TryRLock()
+	s.Require().NoError(err)
+	s.True(locked)
+
+	s.Equal(flock.Shared, gf.ProcessMode())
+	s.Equal(2, s.flock.ProcessHolders())
+
+	// the shared lock of gf is detected before asking the OS.
+	locked, err = s.flock.TryLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = gf.Unlock()
+	s.Require().NoError(err)
+
+	s.Equal(flock.Shared, gf.ProcessMode())
+	s.Equal(1, gf.ProcessHolders())
+
+	upgraded, _, err := s.flock.TryUpgrade()
+	s.Require().NoError(err)
+	s.True(upgraded)
+
+	s.Equal(flock.Exclusive, gf.ProcessMode())
+
+	locked, err = gf.TryRLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	s.Equal(flock.Unlocked, gf.ProcessMode())
+	s.Equal(0, gf.ProcessHolders())
+}
+
+func (s *TestSuite) TestFlock_Holders() {
+	err := s.flock.Lock()
+	s.Require().NoError(err)
+
+	if runtime.GOOS != "linux" {
+		_, err = s.flock.Holders()
+		s.Require().ErrorIs(err, errors.ErrUnsupported)
+
+		return
+	}
+
+	holders, err := flock.LockHolders(s.path)
+	s.Require().NoError(err)
+	s.Require().Len(holders, 1)
+	s.Equal(flock.Exclusive, holders[0].Mode)
+	s.False(holders[0].Blocked)
+
+	if holders[0].Kind == flock.KindOFD {
+		s.Equal(-1, holders[0].PID)
+	} else {
+		s.Equal(flock.KindFlock, holders[0].Kind)
+		s.Equal(os.Getpid(), holders[0].PID)
+	}
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	holders, err = s.flock.Holders()
+	s.Require().NoError(err)
+	s.Empty(holders)
+}
+
+func (s *TestSuite) TestFlock_Heartbeat() {
+	f := flock.New(s.path, append(s.opts, flock.Heartbeat(10*time.Millisecond))...)
+
+	err := f.Lock()
+	s.Require().NoError(err)
+
+	old := time.Now().Add(-time.Hour)
+
+	err = os.Chtimes(s.path, old, old)
+	s.Require().NoError(err)
+
+	s.Eventually(func() bool {
+		fi, err := f.Stat()
+		return err == nil && time.Since(fi.ModTime()) < time.Minute
+	}, time.Second, 10*time.Millisecond)
+
+	err = f.Unlock()
+	s.Require().NoError(err)
+
+	err = os.Chtimes(s.path, old, old)
+	s.Require().NoError(err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	fi, err := f.Stat()
+	s.Require().NoError(err)
+	s.WithinDuration(old, fi.ModTime(), time.Second)
+}
+
+func (s *TestSuite) TestFlock_Fair() {
+	err := s.flock.Lock()
+	s.Require().NoError(err)
+
+	type result struct {
+		index  int
+		locked bool
+		err    error
+	}
+
+	results := make(chan result, 3)
+
+	var wg sync.WaitGroup
+
+	for i := range 3 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			f := flock.New(s.path, append(s.opts, flock.Fair())...)
+
+			locked, err := f.TryLockContext(ctx, time.Millisecond)
+
+			results <- result{index: i, locked: locked, err: err}
+
+			_ = f.Unlock()
+		}()
+
+		// make sure the waiters arrive in order.
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// a fair TryLock does not jump the queue.
+	locked, err := flock.New(s.path, append(s.opts, flock.Fair())...).TryLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	wg.Wait()
+	close(results)
+
+	var order []int
+
+	for r := range results {
+		s.Require().NoError(r.err)
+		s.True(r.locked)
+
+		order = append(order, r.index)
+	}
+
+	s.Equal([]int{0, 1, 2}, order)
+}
+
+func (s *TestSuite) TestFlock_LockContext() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// happy path
+	err := s.flock.LockContext(ctx)
+	s.Require().NoError(err)
+	s.True(s.flock.Locked())
+
+	// test that the short-circuit works
+	err = s.flock.LockContext(ctx)
+	s.Require().NoError(err)
+
+	// context already canceled
+	cancel()
+
+	gf := flock.New(s.path, s.opts...)
+
+	err = gf.LockContext(ctx)
+	s.Require().ErrorIs(err, context.Canceled)
+	s.False(gf.Locked())
+
+	// timeout while blocked in the kernel
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = gf.LockContext(ctx)
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+	s.False(gf.Locked())
+	s.False(gf.RLocked())
+
+	// the abandoned attempt must not keep the lock once it is released.
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = gf.LockContext(ctx)
+	s.Require().NoError(err)
+	s.True(gf.Locked())
+
+	err = gf.Unlock()
+	s.Require().NoError(err)
+}
+
+func (s *TestSuite) TestFlock_LockContext_shared() {
+	switch runtime.GOOS {
+	case "aix", "solaris", "illumos":
+		s.T().Skip("a second shared lock cannot be taken in the same process with POSIX locks")
+	}
+
+	err := s.flock.RLock()
+	s.Require().NoError(err)
+
+	gf := flock.New(s.path, s.opts...)
+
+	err = gf.RLock()
+	s.Require().NoError(err)
+
+	// the shared lock is kept while the conversion fails.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = s.flock.LockContext(ctx)
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+	s.True(s.flock.RLocked())
+
+	err = gf.Unlock()
+	s.Require().NoError(err)
+
+	locked, err := gf.TryLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = s.flock.LockContext(ctx)
+	s.Require().NoError(err)
+	s.True(s.flock.Locked())
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+}
+
+func (s *TestSuite) TestFlock_RLockContext() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// happy path
+	err := s.flock.RLockContext(ctx)
+	s.Require().NoError(err)
+	s.True(s.flock.RLocked())
+
+	// timeout while blocked in the kernel
+	_ = s.flock.Unlock()
+	_ = s.flock.Lock()
+
+	tctx, tcancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer tcancel()
+
+	gf := flock.New(s.path, s.opts...)
+
+	err = gf.RLockContext(tctx)
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+	s.False(gf.RLocked())
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	err = gf.RLockContext(ctx)
+	s.Require().NoError(err)
+	s.True(gf.RLocked())
+
+	err = gf.Unlock()
+	s.Require().NoError(err)
+}
+
+func (s *TestSuite) TestFlock_Upgrade() {
+	_, err := s.flock.Upgrade()
+	s.Require().ErrorIs(err, flock.ErrNotLocked)
+
+	err = s.flock.RLock()
+	s.Require().NoError(err)
+
+	_, err = s.flock.Upgrade()
+	s.Require().NoError(err)
+	s.True(s.flock.Locked())
+	s.False(s.flock.RLocked())
+
+	// test that the short-circuit works
+	atomic, err := s.flock.Upgrade()
+	s.Require().NoError(err)
+	s.True(atomic)
+
+	locked, err := flock.New(s.path, s.opts...).TryRLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	_, err = s.flock.Downgrade()
+	s.Require().NoError(err)
+	s.False(s.flock.Locked())
+	s.True(s.flock.RLocked())
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	_, err = s.flock.Downgrade()
+	s.Require().ErrorIs(err, flock.ErrNotLocked)
+}
+
+func (s *TestSuite) TestFlock_TryUpgrade() {
+	switch runtime.GOOS {
+	case "aix", "solaris", "illumos":
+		s.T().Skip("a second shared lock cannot be taken in the same process with POSIX locks")
+	}
+
+	err := s.flock.RLock()
+	s.Require().NoError(err)
+
+	gf := flock.New(s.path, s.opts...)
+
+	err = gf.RLock()
+	s.Require().NoError(err)
+
+	upgraded, _, err := s.flock.TryUpgrade()
+	s.Require().NoError(err)
+	s.False(upgraded)
+	s.True(s.flock.RLocked())
+
+	err = gf.Unlock()
+	s.Require().NoError(err)
+
+	upgraded, _, err = s.flock.TryUpgrade()
+	s.Require().NoError(err)
+	s.True(upgraded)
+	s.True(s.flock.Locked())
+	s.False(s.flock.RLocked())
+}
+
+func (s *TestSuite) TestFlock_Reentrant() {
+	f := flock.New(s.path, append(s.opts, flock.Reentrant())...)
+
+	err := f.Lock()
+	s.Require().NoError(err)
+
+	locked, err := f.TryLock()
+	s.Require().NoError(err)
+	s.True(locked)
+
+	// a shared lock is satisfied by the exclusive lock.
+	err = f.RLock()
+	s.Require().NoError(err)
+	s.Equal(3, f.Depth())
+	s.True(f.Locked())
+	s.False(f.RLocked())
+
+	err = f.Unlock()
+	s.Require().NoError(err)
+
+	err = f.Unlock()
+	s.Require().NoError(err)
+	s.Equal(1, f.Depth())
+	s.True(f.Locked())
+
+	locked, err = flock.New(s.path, s.opts...).TryLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = f.Unlock()
+	s.Require().NoError(err)
+	s.Equal(0, f.Depth())
+	s.False(f.Locked())
+
+	err = f.Unlock()
+	s.Require().ErrorIs(err, flock.ErrNotLocked)
+}
+
+func (s *TestSuite) TestFlock_Reentrant_downgrade() {
+	f := flock.New(s.path, append(s.opts, flock.Reentrant())...)
+
+	err := f.RLock()
+	s.Require().NoError(err)
+
+	err = f.Lock()
+	s.Require().NoError(err)
+	s.Equal(2, f.Depth())
+	s.True(f.Locked())
+
+	// releasing the inner exclusive hold goes back to the outer shared hold.
+	err = f.Unlock()
+	s.Require().NoError(err)
+	s.Equal(1, f.Depth())
+	s.False(f.Locked())
+	s.True(f.RLocked())
+
+	err = f.Unlock()
+	s.Require().NoError(err)
+	s.False(f.RLocked())
+}
+
+func (s *TestSuite) TestFlock_Acquire() {
+	shared, err := s.flock.Acquire(flock.Shared)
+	s.Require().NoError(err)
+	s.Equal(flock.Shared, shared.Mode())
+	s.WithinDuration(time.Now(), shared.AcquiredAt(), time.Second)
+	s.Same(s.flock, shared.Flock())
+
+	exclusive, err := s.flock.Acquire(flock.Exclusive)
+	s.Require().NoError(err)
+	s.True(s.flock.Locked())
+
+	// releasing the shared lock must not drop the exclusive lock.
+	err = shared.Release()
+	s.Require().NoError(err)
+	s.True(s.flock.Locked())
+	s.False(s.flock.RLocked())
+
+	locked, err := flock.New(s.path, s.opts...).TryRLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = shared.Release()
+	s.Require().ErrorIs(err, flock.ErrReleased)
+
+	err = exclusive.Release()
+	s.Require().NoError(err)
+	s.False(s.flock.Locked())
+
+	_, err = s.flock.Acquire(flock.Unlocked)
+	s.Require().ErrorIs(err, os.ErrInvalid)
+}
+
+func (s *TestSuite) TestFlock_Acquire_sameMode() {
+	first, err := s.flock.Acquire(flock.Shared)
+	s.Require().NoError(err)
+
+	second, err := s.flock.TryAcquire(flock.Shared)
+	s.Require().NoError(err)
+	s.Require().NotNil(second)
+
+	contender := flock.New(s.path, s.opts...)
+
+	// the lock is released with the last handle of the mode.
+	err = first.Release()
+	s.Require().NoError(err)
+	s.True(s.flock.RLocked())
+
+	locked, err := contender.TryLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = second.Release()
+	s.Require().NoError(err)
+	s.False(s.flock.RLocked())
+
+	locked, err = contender.TryLock()
+	s.Require().NoError(err)
+	s.True(locked)
+
+	err = contender.Unlock()
+	s.Require().NoError(err)
+}
+
+func (s *TestSuite) TestFlock_RLock_whileExclusive() {
+	err := s.flock.Lock()
+	s.Require().NoError(err)
+
+	// RLock takes the shared lock, unlike Acquire.
+	err = s.flock.RLock()
+	s.Require().NoError(err)
+	s.True(s.flock.RLocked())
+
+	if runtime.GOOS == "linux" {
+		// the OS replaced the exclusive lock with the shared lock.
+		holders, err := flock.LockHolders(s.path)
+		s.Require().NoError(err)
+		s.Require().Len(holders, 1)
+		s.Equal(flock.Shared, holders[0].Mode)
+	}
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+}
+
+func (s *TestSuite) TestFlock_Acquire_sharedWhileExclusive() {
+	switch runtime.GOOS {
+	case "aix", "solaris", "illumos":
+		s.T().Skip("a second shared lock cannot be taken in the same process with POSIX locks")
+	}
+
+	exclusive, err := s.flock.Acquire(flock.Exclusive)
+	s.Require().NoError(err)
+
+	// the shared lock request must not downgrade the exclusive lock.
+	shared, err := s.flock.Acquire(flock.Shared)
+	s.Require().NoError(err)
+	s.True(s.flock.Locked())
+
+	contender := flock.New(s.path, s.opts...)
+
+	locked, err := contender.TryRLock()
+	s.Require().NoError(err)
+	s.False(locked)
+
+	// releasing the exclusive lock downgrades it for the shared lock holder.
+	err = exclusive.Release()
+	s.Require().NoError(err)
+	s.False(s.flock.Locked())
+	s.True(s.flock.RLocked())
+
+	locked, err = contender.TryRLock()
+	s.Require().NoError(err)
+	s.True(locked)
+
+	err = contender.Unlock()
+	s.Require().NoError(err)
+
+	err = shared.Release()
+	s.Require().NoError(err)
+	s.False(s.flock.RLocked())
+}
+
+func (s *TestSuite) TestFlock_TryAcquire() {
+	exclusive, err := s.flock.TryAcquire(flock.Exclusive)
+	s.Require().NoError(err)
+	s.Require().NotNil(exclusive)
+
+	held, err := flock.New(s.path, s.opts...).TryAcquire(flock.Shared)
+	s.Require().NoError(err)
+	s.Nil(held)
+
+	// the handle is stale once the lock is released through the *Flock.
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+
+	err = exclusive.Release()
+	s.Require().ErrorIs(err, flock.ErrReleased)
+}
+
+func (s *TestSuite) TestFlock_AcquireContext_reentrant() {
+	f := flock.New(s.path, append(s.opts, flock.Reentrant())...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	outer, err := f.AcquireContext(ctx, flock.Exclusive)
+	s.Require().NoError(err)
+
+	inner, err := f.AcquireContext(ctx, flock.Shared)
+	s.Require().NoError(err)
+	s.Equal(2, f.Depth())
+
+	// releasing out of order keeps the shared hold.
+	err = outer.Release()
+	s.Require().NoError(err)
+	s.False(f.Locked())
+	s.True(f.RLocked())
+
+	err = inner.Release()
+	s.Require().NoError(err)
+	s.Equal(0, f.Depth())
+	s.False(f.RLocked())
+}
+
 func (s *TestSuite) TestFlock_Unlock() {
 	err := s.flock.Unlock()
 	s.Require().NoError(err)
@@ -351,3 +959,103 @@ func (s *TestSuite) TestFlock_Stat() {
 	// The modification time should be approximately the same as before
 	s.WithinDuration(modTime, info.ModTime(), 100*time.Millisecond)
 }
+
+func (s *TestSuite) TestFlock_TryLockRange() {
+	if s.dir {
+		s.T().Skip("byte-range locks are not supported on directories")
+	}
+
+	locked, err := s.flock.TryLockRange(0, 10)
+	s.Require().NoError(err)
+	s.True(locked)
+	s.Equal([]flock.Range{{Offset: 0, Length: 10, Exclusive: true}}, s.flock.Ranges())
+
+	// test that the short-circuit works
+	locked, err = s.flock.TryLockRange(2, 4)
+	s.Require().NoError(err)
+	s.True(locked)
+
+	locked, err = s.flock.TryRLockRange(20, 0)
+	s.Require().NoError(err)
+	s.True(locked)
+	s.Equal([]flock.Range{
+		{Offset: 0, Length: 10, Exclusive: true},
+		{Offset: 20, Length: 0, Exclusive: false},
+	}, s.flock.Ranges())
+
+	s.False(s.flock.Locked())
+	s.False(s.flock.RLocked())
+
+	_, err = s.flock.TryLockRange(-1, 10)
+	s.Require().ErrorIs(err, os.ErrInvalid)
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+	s.Empty(s.flock.Ranges())
+}
+
+func (s *TestSuite) TestFlock_TryLockRange_process() {
+	if s.dir {
+		s.T().Skip("byte-range locks are not supported on directories")
+	}
+
+	err := s.flock.LockRange(0, 10)
+	s.Require().NoError(err)
+
+	// the *Flock values of the process conflict with each other.
+	gf := flock.New(s.path, s.opts...)
+
+	locked, err := gf.TryLockRange(5, 10)
+	s.Require().NoError(err)
+	s.False(locked)
+
+	locked, err = gf.TryRLockRange(10, 10)
+	s.Require().NoError(err)
+	s.True(locked)
+
+	// closing another descriptor of the file does not release the ranges.
+	other := flock.New(s.path, s.opts...)
+
+	_, _ = other.TryRLock()
+
+	err = other.Unlock()
+	s.Require().NoError(err)
+
+	locked, err = flock.New(s.path, s.opts...).TryRLockRange(0, 10)
+	s.Require().NoError(err)
+	s.False(locked)
+
+	err = gf.Unlock()
+	s.Require().NoError(err)
+
+	err = s.flock.Unlock()
+	s.Require().NoError(err)
+}
+
+func (s *TestSuite) TestFlock_UnlockRange() {
+	if s.dir {
+		s.T().Skip("byte-range locks are not supported on directories")
+	}
+
+	err := s.flock.LockRange(0, 100)
+	s.Require().NoError(err)
+
+	// release the middle of the range: the held range is split.
+	err = s.flock.UnlockRange(40, 20)
+	s.Require().NoError(err)
+	s.Equal([]flock.Range{
+		{Offset: 0, Length: 40, Exclusive: true},
+		{Offset: 60, Length: 40, Exclusive: true},
+	}, s.flock.Ranges())
+
+	// unlocking a range that is not held is a no-op.
+	err = s.flock.UnlockRange(200, 10)
+	s.Require().NoError(err)
+
+	err = s.flock.UnlockRange(0, 0)
+	s.Require().NoError(err)
+	s.Empty(s.flock.Ranges())
+
+	_, err = s.flock.Stat()
+	s.Require().NoError(err)
+}
@@ -8,7 +8,9 @@
 package flock
 
 import (
+	"context"
 	"errors"
+	"io/fs"
 	"os"
 
 	"golang.org/x/sys/unix"
@@ -26,6 +28,7 @@ import (
 // this may transparently replace the shared lock with an exclusive lock on some UNIX-like operating systems.
 // Be careful when using exclusive locks in conjunction with shared locks (RLock()),
 // because calling Unlock() may accidentally release the exclusive lock that was once a shared lock.
+// Use Upgrade() to convert a shared lock explicitly.
 func (f *Flock) Lock() error {
 	return f.lock(&f.l, unix.LOCK_EX)
 }
@@ -45,10 +48,26 @@ func (f *Flock) lock(locked *bool, flag int) error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if *locked {
+	if f.reenter(locked) {
 		return nil
 	}
 
+	leave, err := f.waitTurn(context.Background())
+	if err != nil {
+		return err
+	}
+
+	defer leave()
+
+	_, err = f.verified(func() (bool, error) {
+		return true, f.lockFh(locked, flag)
+	})
+
+	return err
+}
+
+// lockFh opens the file if needed, and takes the lock.
+func (f *Flock) lockFh(locked *bool, flag int) error {
 	if f.fh == nil {
 		if err := f.setFh(f.flag); err != nil {
 			return err
@@ -57,7 +76,7 @@ func (f *Flock) lock(locked *bool, flag int) error {
 		defer f.ensureFhState()
 	}
 
-	err := unix.Flock(int(f.fh.Fd()), flag)
+	err := f.flock(flag)
 	if err != nil {
 		shouldRetry, reopenErr := f.reopenFDOnError(err)
 		if reopenErr != nil {
@@ -68,13 +87,13 @@ func (f *Flock) lock(locked *bool, flag int) error {
 			return err
 		}
 
-		err = unix.Flock(int(f.fh.Fd()), flag)
+		err = f.flock(flag)
 		if err != nil {
 			return err
 		}
 	}
 
-	*locked = true
+	f.acquired(locked)
 
 	return nil
 }
@@ -84,8 +103,9 @@ func (f *Flock) lock(locked *bool, flag int) error {
 // so while it is running the Locked() and RLocked() functions will be blocked.
 //
 // This function short-circuits if we are unlocked already.
-// If not, it calls unix.LOCK_UN on the file and closes the file descriptor.
-// It does not remove the file from disk. It's up to your application to do.
+// If not, it calls unix.LOCK_UN on the file, releases any byte-range lock, and closes the file descriptor.
+// It does not remove the file from disk, unless the RemoveOnUnlock option is used.
+// It's up to your application to do.
 //
 // Please note,
 // if your shared lock became an exclusive lock,
@@ -95,14 +115,34 @@ func (f *Flock) Unlock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
+	if keep, err := f.unhold(); keep {
+		return err
+	}
+
+	return f.unlock()
+}
+
+// unlock releases every lock held by the *Flock and closes the file descriptor.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) unlock() error {
 	// If we aren't locked or if the lockfile instance is nil
 	// just return a nil error because we are unlocked.
-	if (!f.l && !f.r) || f.fh == nil {
+	if !f.held() || f.fh == nil {
 		return nil
 	}
 
+	// Clear the owner record and remove the file while the exclusive lock is still held.
+	f.clearOwner()
+	f.remove()
+
+	if len(f.ranges) > 0 {
+		if err := f.unlockRegion(Range{}); err != nil {
+			return err
+		}
+	}
+
 	// Mark the file as unlocked.
-	err := unix.Flock(int(f.fh.Fd()), unix.LOCK_UN)
+	err := f.unlockFile()
 	if err != nil {
 		return err
 	}
@@ -112,6 +152,17 @@ func (f *Flock) Unlock() error {
 	return nil
 }
 
+// unlockFile releases the whole-file lock, leaving the byte-range locks alone.
+func (f *Flock) unlockFile() error {
+	return f.flock(unix.LOCK_UN)
+}
+
+// recordWholeFile reports whether the whole-file lock is a record lock on the same file descriptor as the byte-range locks,
+// in which case they are the same lock and cannot be mixed.
+func (f *Flock) recordWholeFile() bool {
+	return f.ofd && ofdAvailable
+}
+
 // TryLock is the preferred function for taking an exclusive file lock.
 // This function takes an RW-mutex lock before it tries to lock the file,
 // so there is the possibility that this function may block for a short time
@@ -142,10 +193,21 @@ func (f *Flock) try(locked *bool, flag int) (bool, error) {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if *locked {
+	if f.reenter(locked) {
 		return true, nil
 	}
 
+	if f.queued() {
+		return false, nil
+	}
+
+	return f.verified(func() (bool, error) {
+		return f.tryFh(locked, flag)
+	})
+}
+
+// tryFh opens the file if needed, and tries to take the lock.
+func (f *Flock) tryFh(locked *bool, flag int) (bool, error) {
 	if f.fh == nil {
 		if err := f.setFh(f.flag); err != nil {
 			return false, err
@@ -154,16 +216,20 @@ func (f *Flock) try(locked *bool, flag int) (bool, error) {
 		defer f.ensureFhState()
 	}
 
+	if f.contended(locked == &f.l) {
+		return false, nil
+	}
+
 	var retried bool
 
 retry:
-	err := unix.Flock(int(f.fh.Fd()), flag|unix.LOCK_NB)
+	err := f.flock(flag | unix.LOCK_NB)
 
 	switch {
 	case errors.Is(err, unix.EWOULDBLOCK):
 		return false, nil
 	case err == nil:
-		*locked = true
+		f.acquired(locked)
 		return true, nil
 	}
 
@@ -180,6 +246,71 @@ retry:
 	return false, err
 }
 
+// flock applies the flock(2) operation how to the file,
+// or the equivalent open file description lock if UseOFDLocks is set.
+func (f *Flock) flock(how int) error {
+	if !f.ofd || !ofdAvailable {
+		return unix.Flock(int(f.fh.Fd()), how)
+	}
+
+	var lt int16
+
+	switch how &^ unix.LOCK_NB {
+	case unix.LOCK_EX:
+		lt = unix.F_WRLCK
+	case unix.LOCK_SH:
+		lt = unix.F_RDLCK
+	default:
+		lt = unix.F_UNLCK
+	}
+
+	// The whole-file lock would replace the byte-range locks.
+	if lt != unix.F_UNLCK && len(f.ranges) > 0 {
+		return &fs.PathError{Op: "Lock", Path: f.path, Err: errRecordLockMixed}
+	}
+
+	err := setlkRange(f.fh.Fd(), f.setlkCmd(how&unix.LOCK_NB == 0), lt, Range{})
+	if errors.Is(err, unix.EACCES) {
+		// POSIX allows EACCES instead of EAGAIN for a conflicting lock.
+		return unix.EWOULDBLOCK
+	}
+
+	return err
+}
+
+// convertLock converts the lock held through the file descriptor to an exclusive or a shared lock.
+// It reports whether the conversion was atomic,
+// and clears the lock state if the previous lock was lost by a failed conversion.
+func (f *Flock) convertLock(exclusive, wait bool) (bool, bool, error) {
+	// The kernel converts open file description locks atomically,
+	// but flock(2) releases the previous lock before taking the new one.
+	atomic := f.ofd && ofdAvailable
+
+	how, prev := unix.LOCK_SH, unix.LOCK_EX
+	if exclusive {
+		how, prev = unix.LOCK_EX, unix.LOCK_SH
+	}
+
+	if !wait {
+		how |= unix.LOCK_NB
+	}
+
+	err := f.flock(how)
+	if err == nil {
+		return true, atomic, nil
+	}
+
+	if !atomic && f.flock(prev|unix.LOCK_NB) != nil {
+		f.l, f.r = false, false
+	}
+
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return false, atomic, nil
+	}
+
+	return false, atomic, err
+}
+
 // reopenFDOnError determines whether we should reopen the file handle in readwrite mode and try again.
 // This comes from `util-linux/sys-utils/flock.c`:
 // > Since Linux 3.4 (commit 55725513)
@@ -209,3 +340,7 @@ func (f *Flock) reopenFDOnError(err error) (bool, error) {
 
 	return true, nil
 }
+
+// adoptOwner transfers the lock bookkeeping of g to f.
+// The lock belongs to the file descriptor, so there is nothing to transfer.
+func (f *Flock) adoptOwner(_ *Flock) {}
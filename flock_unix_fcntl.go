@@ -11,6 +11,8 @@
 // which attach to an (inode, process) pair rather than a file descriptor.
 // To avoid unlocking files prematurely when the same file is opened through different descriptors,
 // we allow only one read-lock at a time.
+// A second *Flock of the process cannot take a shared lock on a file while the first one holds it,
+// even though other processes can.
 //
 // This code is adapted from the Go package (go.22):
 // https://github.com/golang/go/blob/release-branch.go1.22/src/cmd/go/internal/lockedfile/internal/filelock/filelock_fcntl.go
@@ -20,6 +22,7 @@
 package flock
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/fs"
@@ -88,6 +91,7 @@ var (
 // this may transparently replace the shared lock with an exclusive lock on some UNIX-like operating systems.
 // Be careful when using exclusive locks in conjunction with shared locks (RLock()),
 // because calling Unlock() may accidentally release the exclusive lock that was once a shared lock.
+// Use Upgrade() to convert a shared lock explicitly.
 func (f *Flock) Lock() error {
 	return f.lock(&f.l, writeLock)
 }
@@ -99,6 +103,10 @@ func (f *Flock) Lock() error {
 //
 // If we are already shared-locked, this function short-circuits and
 // returns immediately assuming it can take the mutex lock.
+//
+// POSIX record locks belong to the process, and closing any descriptor of the file releases them:
+// only one *Flock of the process can hold a shared lock on a file at a time,
+// so RLock waits until the other *Flock values of the process release the file, and TryRLock returns false.
 func (f *Flock) RLock() error {
 	return f.lock(&f.r, readLock)
 }
@@ -107,10 +115,26 @@ func (f *Flock) lock(locked *bool, flag lockType) error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if *locked {
+	if f.reenter(locked) {
 		return nil
 	}
 
+	leave, err := f.waitTurn(context.Background())
+	if err != nil {
+		return err
+	}
+
+	defer leave()
+
+	_, err = f.verified(func() (bool, error) {
+		return true, f.lockFh(locked, flag)
+	})
+
+	return err
+}
+
+// lockFh opens the file if needed, and takes the lock.
+func (f *Flock) lockFh(locked *bool, flag lockType) error {
 	if f.fh == nil {
 		if err := f.setFh(f.flag); err != nil {
 			return err
@@ -124,7 +148,7 @@ func (f *Flock) lock(locked *bool, flag lockType) error {
 		return err
 	}
 
-	*locked = true
+	f.acquired(locked)
 
 	return nil
 }
@@ -143,6 +167,11 @@ func (f *Flock) doLock(cmd cmdType, lt lockType, blocking bool) (bool, error) {
 	// Note(ldez): don't replace `syscall.Stat_t` by `unix.Stat_t` because `FileInfo.Sys()` returns `syscall.Stat_t`
 	ino := fi.Sys().(*syscall.Stat_t).Ino
 
+	// The whole-file lock would replace the byte-range locks of the process.
+	if len(f.ranges) > 0 || f.rangesHeld(statFileID(fi)) {
+		return false, &fs.PathError{Op: lt.String(), Path: f.path, Err: errRecordLockMixed}
+	}
+
 	mu.Lock()
 
 	if i, dup := inodes[f]; dup && i != ino {
@@ -150,7 +179,7 @@ func (f *Flock) doLock(cmd cmdType, lt lockType, blocking bool) (bool, error) {
 		return false, &fs.PathError{
 			Op:   lt.String(),
 			Path: f.Path(),
-			Err:  errors.New("inode for file changed since last Lock or RLock"),
+			Err:  ErrFileReplaced,
 		}
 	}
 
@@ -169,7 +198,9 @@ func (f *Flock) doLock(cmd cmdType, lt lockType, blocking bool) (bool, error) {
 
 	case !blocking:
 		// Already owned: cannot take the lock.
+		delete(inodes, f)
 		mu.Unlock()
+
 		return false, nil
 
 	default:
@@ -270,14 +301,36 @@ func (f *Flock) Unlock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
+	if keep, err := f.unhold(); keep {
+		return err
+	}
+
+	return f.unlock()
+}
+
+// unlock releases every lock held by the *Flock and closes the file descriptor.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) unlock() error {
 	// If we aren't locked or if the lockfile instance is nil
 	// just return a nil error because we are unlocked.
-	if (!f.l && !f.r) || f.fh == nil {
+	if !f.held() || f.fh == nil {
 		return nil
 	}
 
-	if err := f.doUnlock(); err != nil {
-		return err
+	// Clear the owner record and remove the file while the exclusive lock is still held.
+	f.clearOwner()
+	f.remove()
+
+	if len(f.ranges) > 0 {
+		if err := f.unlockRegion(Range{}); err != nil {
+			return err
+		}
+	}
+
+	if f.l || f.r {
+		if err := f.unlockFile(); err != nil {
+			return err
+		}
 	}
 
 	f.reset()
@@ -285,6 +338,18 @@ func (f *Flock) Unlock() error {
 	return nil
 }
 
+// unlockFile releases the whole-file lock.
+// Byte-range locks cannot be held at the same time, as they are the same record lock.
+func (f *Flock) unlockFile() error {
+	return f.doUnlock()
+}
+
+// recordWholeFile reports whether the whole-file lock is a record lock on the same file descriptor as the byte-range locks,
+// in which case they are the same lock and cannot be mixed.
+func (f *Flock) recordWholeFile() bool {
+	return true
+}
+
 // https://github.com/golang/go/blob/09aeb6e33ab426eff4676a3baf694d5a3019e9fc/src/cmd/go/internal/lockedfile/internal/filelock/filelock_fcntl.go#L163
 func (f *Flock) doUnlock() (err error) {
 	var owner *Flock
@@ -324,6 +389,60 @@ func (f *Flock) doUnlock() (err error) {
 	return err
 }
 
+// convertLock converts the lock held through the file descriptor to an exclusive or a shared lock.
+// The *Flock already owns the inode lock, and the kernel converts POSIX locks atomically.
+func (f *Flock) convertLock(exclusive, wait bool) (bool, bool, error) {
+	lt := readLock
+	if exclusive {
+		lt = writeLock
+	}
+
+	cmd := tryLock
+	if wait {
+		cmd = waitLock
+	}
+
+	for {
+		err := setlkw(f.fh.Fd(), cmd, lt)
+
+		switch {
+		case err == nil:
+			return true, true, nil
+		case wait && errors.Is(err, unix.EDEADLK):
+			// EDEADLK is treated as always spurious, see doLock.
+			time.Sleep(conversionRetryDelay)
+		case !wait && (errors.Is(err, unix.EACCES) || errors.Is(err, unix.EAGAIN)):
+			// The read lock is still held.
+			return false, true, nil
+		default:
+			return false, true, &fs.PathError{
+				Op:   lt.String(),
+				Path: f.Path(),
+				Err:  err,
+			}
+		}
+	}
+}
+
+// adoptOwner transfers the ownership of the inode lock held by g to f.
+func (f *Flock) adoptOwner(g *Flock) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ino, ok := inodes[g]
+	if !ok {
+		return
+	}
+
+	delete(inodes, g)
+	inodes[f] = ino
+
+	if l := locks[ino]; l.owner == g {
+		l.owner = f
+		locks[ino] = l
+	}
+}
+
 // TryLock is the preferred function for taking an exclusive file lock.
 // This function takes an RW-mutex lock before it tries to lock the file,
 // so there is the possibility that this function may block for a short time
@@ -354,10 +473,21 @@ func (f *Flock) try(locked *bool, flag lockType) (bool, error) {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if *locked {
+	if f.reenter(locked) {
 		return true, nil
 	}
 
+	if f.queued() {
+		return false, nil
+	}
+
+	return f.verified(func() (bool, error) {
+		return f.tryFh(locked, flag)
+	})
+}
+
+// tryFh opens the file if needed, and tries to take the lock.
+func (f *Flock) tryFh(locked *bool, flag lockType) (bool, error) {
 	if f.fh == nil {
 		if err := f.setFh(f.flag); err != nil {
 			return false, err
@@ -366,12 +496,18 @@ func (f *Flock) try(locked *bool, flag lockType) (bool, error) {
 		defer f.ensureFhState()
 	}
 
+	if f.contended(locked == &f.l) {
+		return false, nil
+	}
+
 	hasLock, err := f.doLock(tryLock, flag, false)
 	if err != nil {
 		return false, err
 	}
 
-	*locked = hasLock
+	if hasLock {
+		f.acquired(locked)
+	}
 
 	return hasLock, nil
 }
@@ -0,0 +1,111 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package flock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"runtime"
+)
+
+// ErrFileReplaced is returned when the lock file was unlinked or replaced while the *Flock was using it.
+var ErrFileReplaced = errors.New("lock file was unlinked or replaced")
+
+// Valid reports whether the file locked by the *Flock is still the file at its path:
+// another process may have unlinked or replaced the lock file,
+// in which case the lock no longer excludes the processes that open the path.
+// Files are matched by device and inode (volume and file index on Windows).
+//
+// It returns false if the *Flock holds no lock.
+// Each acquisition checks the file after locking it, and locks the file at the path again if it changed,
+// so Valid only needs to be called to detect changes made while the lock is held.
+func (f *Flock) Valid() (bool, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	if f.fh == nil || !f.held() {
+		return false, nil
+	}
+
+	return f.current()
+}
+
+// RemoveOnUnlock makes Unlock remove the lock file while the exclusive lock is still held,
+// so that no other process can lock the file in between.
+// The processes waiting for the lock on the removed file notice it once they get the lock,
+// and lock the file at the path again, creating it if needed (see Valid()).
+//
+// The file is only removed when the exclusive lock is released,
+// and if the path still refers to the locked file.
+// The removal is best-effort: Unlock does not fail if the file cannot be removed.
+// On Windows, an open file cannot be removed, so the option has no effect.
+func RemoveOnUnlock() Option {
+	return func(f *Flock) {
+		f.removeOnUnlock = true
+	}
+}
+
+// remove removes the lock file before the exclusive lock is released, with the RemoveOnUnlock option.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) remove() {
+	if !f.removeOnUnlock || !f.l || f.fh == nil || runtime.GOOS == "windows" {
+		return
+	}
+
+	if current, err := f.current(); err != nil || !current {
+		return
+	}
+
+	_ = os.Remove(f.path)
+}
+
+// verified calls fn, which opens the file if needed and tries to lock it,
+// until the file it locked is still the file at the path.
+// When fn opened the file and the path now refers to another file,
+// every lock is released, and fn is called again to lock the file at the path.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) verified(fn func() (bool, error)) (bool, error) {
+	for {
+		opened := f.fh == nil
+
+		ok, err := fn()
+		if err != nil || !ok || !opened {
+			return ok, err
+		}
+
+		// The lock is kept if the file cannot be checked.
+		current, err := f.current()
+		if err != nil || current {
+			return true, nil
+		}
+
+		// The file was unlinked or replaced while we were waiting for the lock.
+		if err := f.unlock(); err != nil {
+			return false, err
+		}
+	}
+}
+
+// current reports whether the open file is the file at the path.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) current() (bool, error) {
+	got, err := fileIDOf(f.fh)
+	if err != nil {
+		return false, err
+	}
+
+	want, err := pathFileID(f.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return got == want, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package flock_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlock_Valid(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("an open file cannot be removed on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "valid.lock")
+
+	f := flock.New(path)
+
+	valid, err := f.Valid()
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	err = f.Lock()
+	require.NoError(t, err)
+
+	valid, err = f.Valid()
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	err = os.Remove(path)
+	require.NoError(t, err)
+
+	valid, err = f.Valid()
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	err = f.Unlock()
+	require.NoError(t, err)
+}
+
+func TestFlock_Lock_replaced(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("an open file cannot be removed on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "valid.lock")
+
+	holder := flock.New(path)
+
+	err := holder.Lock()
+	require.NoError(t, err)
+
+	contender := flock.New(path)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- contender.Lock()
+	}()
+
+	// let the contender open the file and wait for the lock.
+	time.Sleep(50 * time.Millisecond)
+
+	// the holder removes the lock file before unlocking it,
+	// so the contender gets the lock on an unlinked file first.
+	err = os.Remove(path)
+	require.NoError(t, err)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	err = <-done
+	require.NoError(t, err)
+
+	valid, err := contender.Valid()
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.FileExists(t, path)
+
+	locked, err := flock.New(path).TryLock()
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	err = contender.Unlock()
+	require.NoError(t, err)
+}
+
+func TestFlock_RemoveOnUnlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("an open file cannot be removed on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "remove.lock")
+
+	holder := flock.New(path, flock.RemoveOnUnlock())
+
+	// a shared lock does not remove the file.
+	err := holder.RLock()
+	require.NoError(t, err)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	err = holder.Lock()
+	require.NoError(t, err)
+
+	contender := flock.New(path)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- contender.Lock()
+	}()
+
+	// let the contender open the file and wait for the lock.
+	time.Sleep(50 * time.Millisecond)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+
+	err = <-done
+	require.NoError(t, err)
+
+	// the contender locked a new file.
+	valid, err := contender.Valid()
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	err = contender.Unlock()
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	err = holder.Lock()
+	require.NoError(t, err)
+
+	err = holder.Unlock()
+	require.NoError(t, err)
+	assert.NoFileExists(t, path)
+}
+
+func TestFlock_Heartbeat_replaced(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("an open file cannot be replaced on Windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "heartbeat.lock")
+
+	f := flock.New(path, flock.Heartbeat(10*time.Millisecond))
+
+	err := f.Lock()
+	require.NoError(t, err)
+
+	defer func() { _ = f.Unlock() }()
+
+	old := time.Now().Add(-time.Hour)
+
+	// another process replaces the lock file while it is locked.
+	replacement := filepath.Join(dir, "replacement")
+
+	err = os.WriteFile(replacement, nil, 0o600)
+	require.NoError(t, err)
+
+	err = os.Chtimes(replacement, old, old)
+	require.NoError(t, err)
+
+	err = os.Rename(replacement, path)
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.WithinDuration(t, old, fi.ModTime(), time.Second)
+}
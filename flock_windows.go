@@ -8,6 +8,7 @@
 package flock
 
 import (
+	"context"
 	"errors"
 
 	"golang.org/x/sys/windows"
@@ -53,10 +54,26 @@ func (f *Flock) lock(locked *bool, flag uint32) error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if *locked {
+	if f.reenter(locked) {
 		return nil
 	}
 
+	leave, err := f.waitTurn(context.Background())
+	if err != nil {
+		return err
+	}
+
+	defer leave()
+
+	_, err = f.verified(func() (bool, error) {
+		return true, f.lockFh(locked, flag)
+	})
+
+	return err
+}
+
+// lockFh opens the file if needed, and takes the lock.
+func (f *Flock) lockFh(locked *bool, flag uint32) error {
 	if f.fh == nil {
 		if err := f.setFh(f.flag); err != nil {
 			return err
@@ -70,7 +87,7 @@ func (f *Flock) lock(locked *bool, flag uint32) error {
 		return err
 	}
 
-	*locked = true
+	f.acquired(locked)
 
 	return nil
 }
@@ -80,27 +97,58 @@ func (f *Flock) lock(locked *bool, flag uint32) error {
 // so while it is running the Locked() and RLocked() functions will be blocked.
 //
 // This function short-circuits if we are unlocked already.
-// If not, it calls UnlockFileEx() on the file and closes the file descriptor.
+// If not, it calls UnlockFileEx() on the file, releases any byte-range lock, and closes the file descriptor.
 // It does not remove the file from disk.
 // It's up to your application to do.
 func (f *Flock) Unlock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
+	if keep, err := f.unhold(); keep {
+		return err
+	}
+
+	return f.unlock()
+}
+
+// unlock releases every lock held by the *Flock and closes the file descriptor.
+// The caller must hold the RW-mutex lock.
+func (f *Flock) unlock() error {
 	// if we aren't locked or if the lockfile instance is nil
 	// just return a nil error because we are unlocked
-	if (!f.l && !f.r) || f.fh == nil {
+	if !f.held() || f.fh == nil {
 		return nil
 	}
 
+	// Clear the owner record and remove the file while the exclusive lock is still held.
+	f.clearOwner()
+	f.remove()
+
+	if len(f.ranges) > 0 {
+		if err := f.unlockRegion(Range{}); err != nil {
+			return err
+		}
+	}
+
 	// mark the file as unlocked
+	if f.l || f.r {
+		if err := f.unlockFile(); err != nil {
+			return err
+		}
+	}
+
+	f.reset()
+
+	return nil
+}
+
+// unlockFile releases the whole-file lock, leaving the byte-range locks alone.
+func (f *Flock) unlockFile() error {
 	err := windows.UnlockFileEx(windows.Handle(f.fh.Fd()), 0, 1, 0, &windows.Overlapped{})
 	if err != nil && !errors.Is(err, windows.Errno(0)) {
 		return err
 	}
 
-	f.reset()
-
 	return nil
 }
 
@@ -133,10 +181,21 @@ func (f *Flock) try(locked *bool, flag uint32) (bool, error) {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if *locked {
+	if f.reenter(locked) {
 		return true, nil
 	}
 
+	if f.queued() {
+		return false, nil
+	}
+
+	return f.verified(func() (bool, error) {
+		return f.tryFh(locked, flag)
+	})
+}
+
+// tryFh opens the file if needed, and tries to take the lock.
+func (f *Flock) tryFh(locked *bool, flag uint32) (bool, error) {
 	if f.fh == nil {
 		if err := f.setFh(f.flag); err != nil {
 			return false, err
@@ -145,6 +204,10 @@ func (f *Flock) try(locked *bool, flag uint32) (bool, error) {
 		defer f.ensureFhState()
 	}
 
+	if f.contended(locked == &f.l) {
+		return false, nil
+	}
+
 	err := windows.LockFileEx(windows.Handle(f.fh.Fd()), flag|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
 	if err != nil && !errors.Is(err, windows.Errno(0)) {
 		if errors.Is(err, ErrorLockViolation) || errors.Is(err, windows.ERROR_IO_PENDING) {
@@ -154,7 +217,61 @@ func (f *Flock) try(locked *bool, flag uint32) (bool, error) {
 		return false, err
 	}
 
-	*locked = true
+	f.acquired(locked)
 
 	return true, nil
 }
+
+// adoptOwner transfers the lock bookkeeping of g to f.
+// The lock belongs to the file descriptor, so there is nothing to transfer.
+func (f *Flock) adoptOwner(_ *Flock) {}
+
+// convertLock converts the lock held through the file handle to an exclusive or a shared lock.
+// It reports whether the conversion was atomic,
+// and clears the lock state if the previous lock was lost by a failed conversion.
+func (f *Flock) convertLock(exclusive, wait bool) (bool, bool, error) {
+	handle := windows.Handle(f.fh.Fd())
+
+	if !exclusive {
+		// A shared lock can be taken over an exclusive lock held through the same handle,
+		// and the first unlock then releases the exclusive lock.
+		err := windows.LockFileEx(handle, winLockfileSharedLock|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+		if err != nil && !errors.Is(err, windows.Errno(0)) {
+			return false, true, err
+		}
+
+		err = windows.UnlockFileEx(handle, 0, 1, 0, &windows.Overlapped{})
+		if err != nil && !errors.Is(err, windows.Errno(0)) {
+			return false, true, err
+		}
+
+		return true, true, nil
+	}
+
+	// LockFileEx cannot convert a lock: the shared lock must be released first.
+	err := windows.UnlockFileEx(handle, 0, 1, 0, &windows.Overlapped{})
+	if err != nil && !errors.Is(err, windows.Errno(0)) {
+		return false, false, err
+	}
+
+	flag := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK)
+	if !wait {
+		flag |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+
+	err = windows.LockFileEx(handle, flag, 0, 1, 0, &windows.Overlapped{})
+	if err == nil || errors.Is(err, windows.Errno(0)) {
+		return true, false, nil
+	}
+
+	relock := windows.LockFileEx(handle, winLockfileSharedLock|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+	if relock != nil && !errors.Is(relock, windows.Errno(0)) {
+		f.l, f.r = false, false
+	}
+
+	if errors.Is(err, ErrorLockViolation) || errors.Is(err, windows.ERROR_IO_PENDING) {
+		return false, false, nil
+	}
+
+	return false, false, err
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+// Package pidfile implements PID files protected by an exclusive file lock.
+//
+// A PID file contains the PID of the running process in decimal, followed by a newline,
+// so that it can be used by init scripts and commands such as kill $(cat app.pid).
+// The file is locked for the life of the process:
+// as the OS releases the lock when the process exits,
+// a PID file left behind by a process that crashed is taken over by the next one.
+package pidfile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/flock"
+)
+
+// ErrRunning is returned when the PID file is locked by another running instance.
+// It is flock.ErrAlreadyRunning, so that the errors of SingleInstance and Create match the same sentinel.
+var ErrRunning = flock.ErrAlreadyRunning
+
+var errInvalidPID = errors.New("invalid pid")
+
+// RunningError is returned by Create when the PID file is locked by another running instance.
+// It wraps ErrRunning.
+type RunningError struct {
+	// Path is the path of the PID file.
+	Path string
+	// PID is the PID of the running instance, or 0 if it could not be read.
+	PID int
+}
+
+func (e *RunningError) Error() string {
+	if e.PID == 0 {
+		return "pidfile " + e.Path + ": " + ErrRunning.Error()
+	}
+
+	return "pidfile " + e.Path + ": " + ErrRunning.Error() + " with pid " + strconv.Itoa(e.PID)
+}
+
+func (e *RunningError) Unwrap() error {
+	return ErrRunning
+}
+
+// PIDFile is a PID file locked by the current process.
+type PIDFile struct {
+	f *flock.Flock
+}
+
+// Create creates the PID file at path, locks it, and writes the PID of the current process in it.
+// If the file is locked by another running instance, it returns a *RunningError with its PID.
+//
+// The options are applied to the underlying *flock.Flock,
+// which always opens the file in read-write mode and removes it on release (see flock.RemoveOnUnlock).
+//
+// On Windows, other processes cannot read the first byte of the file while it is locked,
+// so the PID cannot be read by other processes there.
+func Create(path string, opts ...flock.Option) (*PIDFile, error) {
+	opts = append(slices.Clip(opts), flock.SetFlag(os.O_CREATE|os.O_RDWR), flock.RemoveOnUnlock())
+
+	f := flock.New(path, opts...)
+
+	locked, err := f.TryLock()
+	if err != nil {
+		return nil, err
+	}
+
+	if !locked {
+		pid, _ := Read(path)
+
+		return nil, &RunningError{Path: path, PID: pid}
+	}
+
+	fh := f.File()
+
+	data := []byte(strconv.Itoa(os.Getpid()) + "\n")
+
+	if err := write(fh, data); err != nil {
+		_ = f.Unlock()
+
+		return nil, &fs.PathError{Op: "write", Path: path, Err: err}
+	}
+
+	return &PIDFile{f: f}, nil
+}
+
+// Path returns the path of the PID file.
+func (p *PIDFile) Path() string {
+	return p.f.Path()
+}
+
+// Flock returns the lock of the PID file,
+// for instance to register it with flock.RegisterCleanup.
+func (p *PIDFile) Flock() *flock.Flock {
+	return p.f
+}
+
+// Release clears and removes the PID file, and releases the lock.
+// On Windows, where an open file cannot be removed, the file is only cleared.
+func (p *PIDFile) Release() error {
+	if fh := p.f.File(); fh != nil {
+		_ = fh.Truncate(0)
+	}
+
+	return p.f.Unlock()
+}
+
+// Read returns the PID written in the PID file at path, without locking it.
+// The file may belong to a process that is no longer running:
+// use Create to take it over.
+func Read(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, &fs.PathError{Op: "read", Path: path, Err: errInvalidPID}
+	}
+
+	return pid, nil
+}
+
+func write(fh *os.File, data []byte) error {
+	if err := fh.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := fh.WriteAt(data, 0); err != nil {
+		return err
+	}
+
+	return fh.Sync()
+}
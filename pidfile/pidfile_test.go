@@ -0,0 +1,102 @@
+// Copyright 2015 Tim Heckman. All rights reserved.
+// Copyright 2018-2026 The Gofrs. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !js && !plan9 && !wasip1
+
+package pidfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/gofrs/flock"
+	"github.com/gofrs/flock/pidfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+
+	p, err := pidfile.Create(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, p.Path())
+
+	if runtime.GOOS != "windows" {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(data))
+
+		pid, err := pidfile.Read(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.Getpid(), pid)
+	}
+
+	_, err = pidfile.Create(path)
+	require.ErrorIs(t, err, pidfile.ErrRunning)
+
+	var running *pidfile.RunningError
+
+	require.ErrorAs(t, err, &running)
+	assert.Equal(t, path, running.Path)
+
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.Getpid(), running.PID)
+	}
+
+	err = p.Release()
+	require.NoError(t, err)
+
+	if runtime.GOOS != "windows" {
+		assert.NoFileExists(t, path)
+	}
+
+	// the options of the caller are left alone.
+	opts := make([]flock.Option, 1, 3)
+	opts[0] = flock.SetPermissions(0o600)
+
+	p, err = pidfile.Create(path, opts...)
+	require.NoError(t, err)
+	assert.Nil(t, opts[:3][1])
+
+	err = p.Release()
+	require.NoError(t, err)
+}
+
+func TestCreate_stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+
+	// left behind by a process that crashed.
+	err := os.WriteFile(path, []byte("12345678\n"), 0o600)
+	require.NoError(t, err)
+
+	p, err := pidfile.Create(path)
+	require.NoError(t, err)
+
+	if runtime.GOOS != "windows" {
+		pid, err := pidfile.Read(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.Getpid(), pid)
+	}
+
+	err = p.Release()
+	require.NoError(t, err)
+}
+
+func TestRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+
+	_, err := pidfile.Read(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = os.WriteFile(path, []byte("not a pid\n"), 0o600)
+	require.NoError(t, err)
+
+	_, err = pidfile.Read(path)
+	require.Error(t, err)
+}